@@ -0,0 +1,299 @@
+package enum_tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Name suffixes
+// ---------------------------------------------------------------------------
+
+// serviceSuffix attributes hostnames under a zone to a cloud service.
+type serviceSuffix struct {
+	zone     string
+	provider string
+	service  string
+}
+
+// serviceSuffixes lists the zones the checks know, most specific first.
+var serviceSuffixes = func() []serviceSuffix {
+	list := []serviceSuffix{
+		{s3URL, "aws", "S3 bucket"},
+		{appsURL, "aws", "AWS App"},
+		{blobURL, "azure", "Azure Storage Account (blob)"},
+		{fileURL, "azure", "Azure File Account"},
+		{queueURL, "azure", "Azure Queue Account"},
+		{tableURL, "azure", "Azure Table Account"},
+		{mgmtURL, "azure", "Azure App Management Account"},
+		{vaultURL, "azure", "Azure Key Vault"},
+		{webappURL, "azure", "Azure Website"},
+		{databaseURL, "azure", "Azure Database"},
+		{vmURL, "azure", "Azure Virtual Machine"},
+		{gcpURL, "gcp", "Google bucket"},
+		{fbrtdbURL, "gcp", "Google Firebase Realtime Database"},
+		{appspotURL, "gcp", "Google App Engine app"},
+		{funcURL, "gcp", "Google Cloud Function"},
+		{fbappURL, "gcp", "Google Firebase app"},
+	}
+	for _, zone := range AWS_REGIONS {
+		service := "AWS service"
+		if region, _, ok := strings.Cut(zone, ".amazonaws."); ok {
+			service += " in " + region
+		}
+		list = append(list, serviceSuffix{zone, "aws", service})
+	}
+	// Longest zone first, so scm.azurewebsites.net wins over
+	// azurewebsites.net and regions over amazonaws.com.
+	sort.SliceStable(list, func(i, j int) bool { return len(list[i].zone) > len(list[j].zone) })
+	return list
+}()
+
+// matchSuffix returns the service whose zone contains host.
+func matchSuffix(host string) (serviceSuffix, bool) {
+	for _, s := range serviceSuffixes {
+		if inZone(host, s.zone) {
+			return s, true
+		}
+	}
+	return serviceSuffix{}, false
+}
+
+// ---------------------------------------------------------------------------
+// IP ranges
+// ---------------------------------------------------------------------------
+
+// ipRange is one published provider prefix.
+type ipRange struct {
+	prefix   netip.Prefix
+	provider string
+	service  string
+	region   string
+	generic  bool // covers the whole provider (AMAZON, AzureCloud, ...)
+}
+
+// IPRanges holds the address ranges providers publish: AWS ip-ranges.json,
+// Azure service tags and Google cloud.json, loaded from local copies.
+type IPRanges struct {
+	ranges []ipRange
+}
+
+// NewIPRanges returns an empty set of ranges.
+func NewIPRanges() *IPRanges {
+	return &IPRanges{}
+}
+
+// Len returns the number of prefixes loaded.
+func (r *IPRanges) Len() int {
+	return len(r.ranges)
+}
+
+// rangesFile covers the fields used from the three providers' formats.
+type rangesFile struct {
+	Prefixes []struct {
+		IPPrefix   string `json:"ip_prefix"`  // AWS
+		IPv4Prefix string `json:"ipv4Prefix"` // Google
+		IPv6Prefix string `json:"ipv6Prefix"` // Google
+		Region     string `json:"region"`
+		Scope      string `json:"scope"`
+		Service    string `json:"service"`
+	} `json:"prefixes"`
+	IPv6Prefixes []struct {
+		IPv6Prefix string `json:"ipv6_prefix"`
+		Region     string `json:"region"`
+		Service    string `json:"service"`
+	} `json:"ipv6_prefixes"`
+	Values []struct { // Azure
+		Name       string `json:"name"`
+		Properties struct {
+			Region          string   `json:"region"`
+			SystemService   string   `json:"systemService"`
+			AddressPrefixes []string `json:"addressPrefixes"`
+		} `json:"properties"`
+	} `json:"values"`
+}
+
+// Load adds the ranges of an AWS, Azure or Google ranges file, telling the
+// format apart by its fields.
+func (r *IPRanges) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var f rangesFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("ranges file %s: %w", path, err)
+	}
+
+	before := len(r.ranges)
+	var bad int
+	add := func(cidr string, rng ipRange) {
+		p, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			bad++
+			return
+		}
+		rng.prefix = p.Masked()
+		r.ranges = append(r.ranges, rng)
+	}
+	for _, v := range f.Values {
+		service := v.Properties.SystemService
+		if service == "" {
+			service = v.Name
+		}
+		for _, cidr := range v.Properties.AddressPrefixes {
+			add(cidr, ipRange{provider: "azure", service: service, region: v.Properties.Region,
+				generic: v.Properties.SystemService == ""})
+		}
+	}
+	for _, p := range f.Prefixes {
+		switch {
+		case p.IPPrefix != "":
+			add(p.IPPrefix, ipRange{provider: "aws", service: p.Service, region: p.Region, generic: p.Service == "AMAZON"})
+		case p.IPv4Prefix != "":
+			add(p.IPv4Prefix, ipRange{provider: "gcp", service: p.Service, region: p.Scope})
+		case p.IPv6Prefix != "":
+			add(p.IPv6Prefix, ipRange{provider: "gcp", service: p.Service, region: p.Scope})
+		}
+	}
+	for _, p := range f.IPv6Prefixes {
+		add(p.IPv6Prefix, ipRange{provider: "aws", service: p.Service, region: p.Region, generic: p.Service == "AMAZON"})
+	}
+
+	if len(r.ranges) == before {
+		return fmt.Errorf("ranges file %s has no AWS, Azure or Google prefixes", path)
+	}
+	if bad > 0 {
+		return fmt.Errorf("ranges file %s: %d malformed prefixes", path, bad)
+	}
+	return nil
+}
+
+// match returns the most specific range containing addr, preferring a
+// named service over a provider-wide range of the same size.
+func (r *IPRanges) match(addr netip.Addr) (ipRange, bool) {
+	var best ipRange
+	found := false
+	for _, rng := range r.ranges {
+		if !rng.prefix.Contains(addr) {
+			continue
+		}
+		bits, bestBits := rng.prefix.Bits(), best.prefix.Bits()
+		if !found || bits > bestBits || (bits == bestBits && best.generic && !rng.generic) {
+			best, found = rng, true
+		}
+	}
+	return best, found
+}
+
+// ---------------------------------------------------------------------------
+// Attribution
+// ---------------------------------------------------------------------------
+
+var providerNames = map[string]string{"aws": "AWS", "azure": "Azure", "gcp": "Google"}
+
+// Attribute works out which provider and service each of a target's known
+// hostnames and IP addresses belongs to. Hostnames are matched by their
+// own name and CNAME chain against the zones the checks know, then by the
+// addresses they resolve to against Config.IPRanges. Findings are
+// delivered to fn like Run's.
+func (s *Scanner) Attribute(ctx context.Context, assets []string, fn func(Finding)) error {
+	defer s.session("attribution", fn)()
+
+	var hosts []string
+	for _, a := range assets {
+		if addr, err := netip.ParseAddr(a); err == nil {
+			s.attributeIP(a, addr, nil)
+		} else {
+			hosts = append(hosts, strings.ToLower(strings.TrimSuffix(a, ".")))
+		}
+	}
+	if len(hosts) == 0 {
+		return ctx.Err()
+	}
+
+	s.Printf("[+] Resolving %d hostnames\n", len(hosts))
+	if err := s.checkResolvers(ctx); err != nil {
+		return err
+	}
+	answers, err := s.resolveAll(ctx, hosts)
+	if err != nil {
+		return err
+	}
+	for _, ans := range answers {
+		if s.attributeName(ans) {
+			continue
+		}
+		attributed := false
+		for _, ip := range ans.IPs {
+			if addr, err := netip.ParseAddr(ip); err == nil && s.attributeIP(ans.Name, addr, ans) {
+				attributed = true
+				break
+			}
+		}
+		if !attributed {
+			s.Printf("    [*] No provider found for %s\n", ans.Name)
+		}
+	}
+	return ctx.Err()
+}
+
+// attributeName reports a host whose name or CNAME chain is in a known
+// zone.
+func (s *Scanner) attributeName(ans *DNSAnswer) bool {
+	for _, host := range append([]string{ans.Name}, ans.CNAMEs...) {
+		if m, ok := matchSuffix(host); ok {
+			s.emitResolved(OutputData{
+				Platform: m.provider,
+				Msg:      "Attributed by name to " + m.service,
+				Target:   ans.Name,
+				Access:   "unknown",
+			}, ans)
+			return true
+		}
+	}
+	return false
+}
+
+// attributeIP reports target when addr is in a provider's ranges. ans is
+// the answer target resolved to, if any.
+func (s *Scanner) attributeIP(target string, addr netip.Addr, ans *DNSAnswer) bool {
+	if s.cfg.IPRanges == nil {
+		if ans == nil {
+			s.Printf("    [*] No provider found for %s (no -ip-ranges loaded)\n", target)
+		}
+		return false
+	}
+	rng, ok := s.cfg.IPRanges.match(addr.Unmap())
+	if !ok {
+		if ans == nil {
+			s.Printf("    [*] No provider found for %s\n", target)
+		}
+		return false
+	}
+	service := rng.service
+	if name := providerNames[rng.provider]; !strings.HasPrefix(service, name) {
+		service = name + " " + service
+	}
+	msg := "Attributed by IP range to " + service
+	if rng.region != "" {
+		msg += " in " + rng.region
+	}
+	data := OutputData{
+		Platform: rng.provider,
+		Msg:      msg + " (" + rng.prefix.String() + ")",
+		Target:   target,
+		Access:   "unknown",
+	}
+	if ans != nil {
+		s.emitResolved(data, ans)
+	} else {
+		s.emitFinding(Finding{OutputData: data, Candidate: target})
+	}
+	return true
+}
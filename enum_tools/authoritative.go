@@ -0,0 +1,159 @@
+package enum_tools
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+)
+
+// ---------------------------------------------------------------------------
+// Authoritative mode
+// ---------------------------------------------------------------------------
+
+// Whether a name exists under a cloud service's zone is ultimately decided
+// by the provider's authoritative nameservers. In authoritative mode the
+// NS set of each zone is looked up once through the recursive resolvers,
+// and the candidates are then asked from those servers directly, without
+// recursion, at a bounded rate per server.
+
+// maxAuthServers bounds the nameservers used per zone.
+const maxAuthServers = 8
+
+// authResolver sends every lookup to the authoritative servers of the
+// name's zone, falling back to the recursive resolvers where those can't
+// be found or the answer leads out of the zone.
+type authResolver struct {
+	recursive *dnsClient
+	qps       RateLimit
+	override  string // DNS stand-in that takes every query instead
+
+	mu    sync.Mutex
+	zones map[string]*authZone
+}
+
+// authZone memoizes the authoritative client for names below a zone.
+type authZone struct {
+	mu     sync.Mutex // held while the zone's nameservers are looked up
+	client *dnsClient // nil until a lookup succeeds
+}
+
+func newAuthResolver(recursive *dnsClient, qps RateLimit, override string) *authResolver {
+	return &authResolver{recursive: recursive, qps: qps, override: override, zones: make(map[string]*authZone)}
+}
+
+func (r *authResolver) resolve(ctx context.Context, name string) (*DNSAnswer, error) {
+	c := r.zoneClient(ctx, parentZone(name))
+	if c == nil {
+		return r.recursive.resolve(ctx, name)
+	}
+	ans, err := c.resolve(ctx, name)
+	if err != nil || ans.Rcode != "NOERROR" || len(ans.IPs) > 0 {
+		return ans, err
+	}
+	if len(ans.CNAMEs) == 0 {
+		// No data, or a referral to a delegated subzone.
+		return r.recursive.resolve(ctx, name)
+	}
+	// The chain left the zone; the recursive resolvers take it from there.
+	rest, err := r.recursive.resolve(ctx, ans.Target())
+	ans.CNAMEs = append(ans.CNAMEs, rest.CNAMEs...)
+	ans.IPs, ans.Rcode = rest.IPs, rest.Rcode
+	ans.TTL = min(ans.TTL, rest.TTL)
+	return ans, err
+}
+
+// zoneClient returns the client for the closest zone enclosing zone that
+// has NS records, looking them up on first use. Only a client found is
+// kept: after a failed lookup (a timeout, a cancelled ctx) the next name
+// in the zone tries again, and nil sends this one to the recursive
+// resolvers.
+func (r *authResolver) zoneClient(ctx context.Context, zone string) *dnsClient {
+	if !strings.Contains(zone, ".") {
+		return nil // never query the TLD servers
+	}
+	r.mu.Lock()
+	z, ok := r.zones[zone]
+	if !ok {
+		z = &authZone{}
+		r.zones[zone] = z
+	}
+	r.mu.Unlock()
+
+	// Concurrent lookups of the zone wait for the first one.
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if z.client == nil {
+		z.client = r.lookupZone(ctx, zone)
+	}
+	return z.client
+}
+
+// lookupZone asks the recursive resolvers for zone's NS records, moving up
+// a level when it has none.
+func (r *authResolver) lookupZone(ctx context.Context, zone string) *dnsClient {
+	msg, _, err := r.recursive.exchange(ctx, zone, dnsTypeNS)
+	if err != nil {
+		return nil
+	}
+	var hosts []string
+	for _, rr := range msg.Answers {
+		if rr.Type == dnsTypeNS && rr.Name == zone {
+			hosts = append(hosts, rr.Target)
+		}
+	}
+	if len(hosts) == 0 {
+		return r.zoneClient(ctx, parentZone(zone))
+	}
+	return r.newZoneClient(ctx, zone, hosts)
+}
+
+// newZoneClient resolves a zone's nameservers and returns a client asking
+// them directly, or nil when none could be resolved.
+func (r *authResolver) newZoneClient(ctx context.Context, zone string, hosts []string) *dnsClient {
+	c := &dnsClient{timeout: r.recursive.timeout, norecurse: true, zone: zone, warn: r.recursive.warn}
+	for _, host := range hosts {
+		if len(c.servers) == maxAuthServers {
+			break
+		}
+		ans, err := r.recursive.resolve(ctx, host)
+		if err != nil || len(ans.IPs) == 0 {
+			continue
+		}
+		addr := net.JoinHostPort(ans.IPs[0], "53")
+		if r.override != "" {
+			addr = r.override
+		}
+		c.servers = append(c.servers, &nameserver{
+			spec:  host,
+			proto: "udp",
+			addr:  addr,
+			limit: newTokenBucket(r.qps),
+		})
+	}
+	if len(c.servers) == 0 {
+		if c.warn != nil {
+			c.warn("    [!] No authoritative nameserver of %s resolved, using the resolvers\n", zone)
+		}
+		return nil
+	}
+	if c.warn != nil {
+		c.warn("[*] Asking %d authoritative nameserver(s) of %s directly\n", len(c.servers), zone)
+	}
+	return c
+}
+
+// clients returns the authoritative clients in use.
+func (r *authResolver) clients() []*dnsClient {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*dnsClient
+	seen := make(map[*dnsClient]bool)
+	for _, z := range r.zones {
+		if z.client != nil && !seen[z.client] {
+			seen[z.client] = true
+			out = append(out, z.client)
+		}
+	}
+	return out
+}
@@ -0,0 +1,42 @@
+package enum_tools
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestZoneClientRetriesFailures(t *testing.T) {
+	sim := NewSimulator(&Scenario{Address: "127.0.0.1"})
+	if err := sim.Start("127.0.0.1:0", "127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Close()
+	recursive, err := newDNSClient([]string{sim.DNSAddr()}, nil, false, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newAuthResolver(recursive, RateLimit{RPS: 100}, sim.DNSAddr())
+
+	// A lookup cut short isn't remembered as a zone without servers.
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if c := r.zoneClient(cancelled, "database.windows.net"); c != nil {
+		t.Fatal("found the zone's servers with a cancelled context")
+	}
+	c := r.zoneClient(context.Background(), "database.windows.net")
+	if c == nil || c.zone != "database.windows.net" || len(c.servers) != 1 || c.servers[0].addr != sim.DNSAddr() {
+		t.Fatalf("got %+v, want the simulator as the zone's server", c)
+	}
+	if again := r.zoneClient(cancelled, "database.windows.net"); again != c {
+		t.Error("the zone's servers were looked up again")
+	}
+
+	// Names in a zone without NS records use the closest enclosing zone.
+	if sub := r.zoneClient(context.Background(), "acme.database.windows.net"); sub != c {
+		t.Errorf("subzone got %+v, want the client of database.windows.net", sub)
+	}
+	if tld := r.zoneClient(context.Background(), "net"); tld != nil {
+		t.Error("asked the TLD servers")
+	}
+}
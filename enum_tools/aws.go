@@ -1,10 +1,5 @@
 package enum_tools
 
-import (
-	"fmt"
-	"strings"
-)
-
 const awsBanner = `
 ++++++++++++++++++++++++++
       amazon checks
@@ -16,7 +11,7 @@ const (
 	appsURL = "awsapps.com"
 )
 
-// AWS_REGIONS is provided for reference; currently unused.
+// AWS_REGIONS lists the regional AWS zones, used to attribute hostnames.
 var AWS_REGIONS = []string{
 	"amazonaws.com",
 	"ap-east-1.amazonaws.com",
@@ -40,85 +35,40 @@ var AWS_REGIONS = []string{
 	"sa-east-1.amazonaws.com",
 }
 
-// ---------------------------------------------------------------------------
-// S3 checks
-// ---------------------------------------------------------------------------
-
-func printS3Response(result *HttpResult) bool {
-	data := OutputData{Platform: "aws"}
-
-	switch {
-	case result.StatusCode == 404:
-		// Not found — skip.
-	case strings.Contains(result.Reason, "Bad Request"):
-		// Malformed — skip.
-	case result.StatusCode == 200:
-		data.Msg = "OPEN S3 BUCKET"
-		data.Target = result.URL
-		data.Access = "public"
-		FmtOutput(data)
-		ListBucketContents(result.URL)
-	case result.StatusCode == 403:
-		data.Msg = "Protected S3 Bucket"
-		data.Target = result.URL
-		data.Access = "protected"
-		FmtOutput(data)
-	case strings.Contains(result.Reason, "Slow Down"):
-		fmt.Println("[!] You've been rate limited, skipping rest of check...")
-		return true // breakout
-	default:
-		fmt.Printf("    Unknown status codes being received from %s:\n       %d: %s\n",
-			result.URL, result.StatusCode, result.Reason)
-	}
-	return false
-}
-
-func checkS3Buckets(names []string, threads int) {
-	fmt.Println("[+] Checking for S3 buckets")
-	start := StartTimer()
-
-	var candidates []string
-	for _, name := range names {
-		candidates = append(candidates, fmt.Sprintf("%s.%s", name, s3URL))
-	}
-
-	GetURLBatch(candidates, false, printS3Response, threads, true)
-	StopTimer(start)
-}
-
 // ---------------------------------------------------------------------------
 // AWS Apps checks (WorkDocs, WorkMail, Connect, etc.)
 // ---------------------------------------------------------------------------
 
-func checkAWSApps(names []string, threads int, nameserver, nameserverFile string) {
-	fmt.Println("[+] Checking for AWS Apps")
-	start := StartTimer()
-
-	var candidates []string
-	for _, name := range names {
-		candidates = append(candidates, fmt.Sprintf("%s.%s", name, appsURL))
-	}
-
-	validNames := FastDNSLookup(candidates, nameserver, nameserverFile, nil, threads)
-
-	for _, name := range validNames {
-		FmtOutput(OutputData{
-			Platform: "aws",
-			Msg:      "AWS App Found:",
-			Target:   "https://" + name,
-			Access:   "protected",
-		})
-	}
-
-	StopTimer(start)
+func printAWSApp(s *Scanner, ans *DNSAnswer) {
+	s.emitResolved(OutputData{
+		Platform: "aws",
+		Msg:      "AWS App Found:",
+		Target:   "https://" + ans.Name,
+		Access:   "protected",
+	}, ans)
 }
 
 // ---------------------------------------------------------------------------
-// RunAllAWS is the public entry-point called by main.
+// Registration
 // ---------------------------------------------------------------------------
 
-func RunAllAWS(names []string, cfg *Config) {
-	fmt.Print(awsBanner)
-	checkS3Buckets(names, cfg.Threads)
-	checkAWSApps(names, cfg.Threads, cfg.Nameserver, cfg.NameserverFile)
+func init() {
+	Register(&check{
+		name:     "aws-s3",
+		provider: "aws",
+		service:  "s3",
+		title:    "S3 buckets",
+		build:    urlCandidates("s3", "http", ""),
+		classify: ruleClassifier("aws-s3", "aws"),
+		run:      httpRun(true),
+	})
+	Register(&check{
+		name:     "aws-apps",
+		provider: "aws",
+		service:  "awsapps",
+		title:    "AWS Apps",
+		build:    hostCandidates("awsapps", nil),
+		resolved: printAWSApp,
+		run:      dnsRun,
+	})
 }
@@ -1,8 +1,7 @@
 package enum_tools
 
 import (
-	"fmt"
-	"net/http"
+	"context"
 	"regexp"
 	"strings"
 )
@@ -27,159 +26,55 @@ const (
 )
 
 // ---------------------------------------------------------------------------
-// Storage-account response callback (shared by several checks)
+// Storage-style accounts – resolved first, then probed over HTTP.
 // ---------------------------------------------------------------------------
 
-func printAccountResponse(result *HttpResult) bool {
-	data := OutputData{Platform: "azure"}
-	reason := result.Reason
-	body := result.Body
-
-	switch {
-	case result.StatusCode == 404,
-		strings.Contains(reason, "The requested URI does not represent"):
-		// Not found — skip.
-
-	case strings.Contains(reason, "Server failed to authenticate the request"),
-		strings.Contains(body, "Server failed to authenticate the request"):
-		data.Msg = "Auth-Only Account"
-		data.Target = result.URL
-		data.Access = "protected"
-		FmtOutput(data)
-
-	case strings.Contains(reason, "The specified account is disabled"),
-		strings.Contains(body, "The specified account is disabled"):
-		data.Msg = "Disabled Account"
-		data.Target = result.URL
-		data.Access = "disabled"
-		FmtOutput(data)
-
-	case strings.Contains(reason, "Value for one of the query"),
-		strings.Contains(body, "Value for one of the query"):
-		data.Msg = "HTTP-OK Account"
-		data.Target = result.URL
-		data.Access = "public"
-		FmtOutput(data)
-
-	case strings.Contains(reason, "The account being accessed"),
-		strings.Contains(body, "The account being accessed"):
-		data.Msg = "HTTPS-Only Account"
-		data.Target = result.URL
-		data.Access = "public"
-		FmtOutput(data)
-
-	case strings.Contains(reason, "Unauthorized"),
-		strings.Contains(body, "Unauthorized"):
-		data.Msg = "Unauthorized Account"
-		data.Target = result.URL
-		data.Access = "public"
-		FmtOutput(data)
-
-	default:
-		fmt.Printf("    Unknown status codes being received from %s:\n       %d : %s\n",
-			result.URL, result.StatusCode, reason)
-	}
-	return false
-}
-
-// ---------------------------------------------------------------------------
-// Generic helper – most Azure account checks are identical in shape.
-// ---------------------------------------------------------------------------
-
-func checkAzureAccountType(names []string, threads int, nameserver, nameserverFile, domain, label string) []string {
-	fmt.Printf("[+] Checking for Azure %s\n", label)
-	start := StartTimer()
-
+// accountCandidates builds account hostnames for purely alphanumeric
+// names, the only ones Azure accepts as account names.
+func accountCandidates(service string) func([]string, *Config, map[string][]string) []string {
 	alphaNum := regexp.MustCompile(`[^a-zA-Z0-9]`)
-	var candidates []string
-	for _, name := range names {
-		if !alphaNum.MatchString(name) {
-			candidates = append(candidates, name+"."+domain)
-		}
-	}
-
-	validNames := FastDNSLookup(candidates, nameserver, nameserverFile, nil, threads)
-	GetURLBatch(validNames, false, printAccountResponse, threads, true)
-	StopTimer(start)
-
-	// De-duplicate.
-	seen := make(map[string]bool)
-	var unique []string
-	for _, n := range validNames {
-		if !seen[n] {
-			seen[n] = true
-			unique = append(unique, n)
+	return func(names []string, cfg *Config, _ map[string][]string) []string {
+		ep := cfg.endpoint(service)
+		var candidates []string
+		for _, name := range names {
+			if !alphaNum.MatchString(name) {
+				candidates = append(candidates, ep.Hostname(name, ""))
+			}
 		}
+		return candidates
 	}
-	return unique
 }
 
 // ---------------------------------------------------------------------------
 // Container brute-force
 // ---------------------------------------------------------------------------
 
-func printContainerResponse(result *HttpResult) bool {
-	data := OutputData{Platform: "azure"}
-	reason := result.Reason
-	body := result.Body
-
-	// Early break-out conditions.
-	if strings.Contains(reason, "The specified account is disabled") ||
-		strings.Contains(body, "The specified account is disabled") {
-		fmt.Println("    [!] Breaking out early, account disabled.")
-		return true
-	}
-	if strings.Contains(reason, "not authorized to perform this operation") ||
-		strings.Contains(reason, "not have sufficient permissions") ||
-		strings.Contains(reason, "Public access is not permitted") ||
-		strings.Contains(reason, "Server failed to authenticate the request") ||
-		strings.Contains(body, "not authorized to perform this operation") ||
-		strings.Contains(body, "not have sufficient permissions") ||
-		strings.Contains(body, "Public access is not permitted") ||
-		strings.Contains(body, "Server failed to authenticate the request") {
-		fmt.Println("    [!] Breaking out early, auth required.")
-		return true
+func containerCandidates(_ []string, cfg *Config, deps map[string][]string) []string {
+	if cfg.QuickScan {
+		return nil
 	}
-	if strings.Contains(reason, "Blob API is not yet supported") ||
-		strings.Contains(body, "Blob API is not yet supported") {
-		fmt.Println("    [!] Breaking out early, Hierarchical namespace account")
-		return true
-	}
-
-	switch {
-	case result.StatusCode == 404:
-		// Not found.
-	case result.StatusCode == 200:
-		data.Msg = "OPEN AZURE CONTAINER"
-		data.Target = result.URL
-		data.Access = "public"
-		FmtOutput(data)
-		ListBucketContents(result.URL)
-	case strings.Contains(reason, "One of the request inputs is out of range"),
-		strings.Contains(body, "One of the request inputs is out of range"):
-		// skip
-	case strings.Contains(reason, "The request URI is invalid"),
-		strings.Contains(body, "The request URI is invalid"):
-		// skip
-	default:
-		fmt.Printf("    Unknown status codes being received from %s:\n       %d: %s\n",
-			result.URL, result.StatusCode, reason)
-	}
-	return false
+	return deps["azure-blob"]
 }
 
-func bruteForceContainers(storageAccounts []string, bruteData string, threads int) {
-	fmt.Printf("[*] Checking %d accounts for status before brute-forcing\n", len(storageAccounts))
+func bruteForceContainers(ctx context.Context, s *Scanner, storageAccounts []string, classify func(*HttpResult) bool) error {
+	s.Printf("[*] Checking %d accounts for status before brute-forcing\n", len(storageAccounts))
 
 	var validAccounts []string
+	ep := s.cfg.endpoint("blob")
 	for _, acct := range storageAccounts {
-		resp, err := http.Get("https://" + acct + "/")
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		acctURL := ep.URLForHost(acct, "/", "https")
+		if err := s.limiter.wait(ctx, s, "azure", acct); err != nil {
+			return err
+		}
+		result, err := s.fetch(ctx, acctURL, true, s.cfg.MaxBodySize)
 		if err != nil {
-			fmt.Printf("    [!] Connection error on https://%s: %v\n", acct, err)
+			s.Printf("    [!] Connection error on %s: %v\n", acctURL, err)
 			continue
 		}
-		resp.Body.Close()
-		reason := extractReason(resp.Status)
+		reason := result.Reason
 		if strings.Contains(reason, "Server failed to authenticate the request") ||
 			strings.Contains(reason, "The specified account is disabled") {
 			continue
@@ -187,119 +82,107 @@ func bruteForceContainers(storageAccounts []string, bruteData string, threads in
 		validAccounts = append(validAccounts, acct)
 	}
 
-	cleanNames := GetBrute(bruteData, 3, 63)
-	start := StartTimer()
+	cleanNames := GetBrute(s.cfg.BruteData, 3, 63)
 
-	fmt.Printf("[*] Brute-forcing container names in %d storage accounts\n", len(validAccounts))
+	s.Printf("[*] Brute-forcing container names in %d storage accounts\n", len(validAccounts))
 	for _, acct := range validAccounts {
-		fmt.Printf("[*] Brute-forcing %d container names in %s\n", len(cleanNames), acct)
+		s.Printf("[*] Brute-forcing %d container names in %s\n", len(cleanNames), acct)
 		var candidates []string
 		for _, name := range cleanNames {
-			candidates = append(candidates, fmt.Sprintf("%s/%s/?restype=container&comp=list", acct, name))
+			candidates = append(candidates, ep.URLForHost(acct, "/"+name+"/?restype=container&comp=list", "https"))
+		}
+		if err := s.GetURLBatch(ctx, candidates, true, classify, true); err != nil {
+			return err
 		}
-		GetURLBatch(candidates, true, printContainerResponse, threads, true)
 	}
-	StopTimer(start)
+	return nil
 }
 
 // ---------------------------------------------------------------------------
-// Azure Websites
+// DNS-only checks (websites, databases, virtual machines)
 // ---------------------------------------------------------------------------
 
-func checkAzureWebsites(names []string, nameserver string, threads int, nameserverFile string) {
-	fmt.Println("[+] Checking for Azure Websites")
-	start := StartTimer()
-
-	var candidates []string
-	for _, n := range names {
-		candidates = append(candidates, n+"."+webappURL)
-	}
-
-	FastDNSLookup(candidates, nameserver, nameserverFile, func(hostname string) {
-		FmtOutput(OutputData{
+// printRegisteredName returns a DNS classifier reporting hostnames as
+// registered Azure resources of the given kind.
+func printRegisteredName(kind string) func(*Scanner, *DNSAnswer) {
+	return func(s *Scanner, ans *DNSAnswer) {
+		s.emitResolved(OutputData{
 			Platform: "azure",
-			Msg:      "Registered Azure Website DNS Name",
-			Target:   hostname,
+			Msg:      "Registered Azure " + kind + " DNS Name",
+			Target:   ans.Name,
 			Access:   "public",
-		})
-	}, threads)
-
-	StopTimer(start)
-}
-
-// ---------------------------------------------------------------------------
-// Azure Databases
-// ---------------------------------------------------------------------------
-
-func checkAzureDatabases(names []string, nameserver string, threads int, nameserverFile string) {
-	fmt.Println("[+] Checking for Azure Databases")
-	start := StartTimer()
-
-	var candidates []string
-	for _, n := range names {
-		candidates = append(candidates, n+"."+databaseURL)
+		}, ans)
 	}
-
-	FastDNSLookup(candidates, nameserver, nameserverFile, func(hostname string) {
-		FmtOutput(OutputData{
-			Platform: "azure",
-			Msg:      "Registered Azure Database DNS Name",
-			Target:   hostname,
-			Access:   "public",
-		})
-	}, threads)
-
-	StopTimer(start)
 }
 
 // ---------------------------------------------------------------------------
-// Azure Virtual Machines
+// Registration
 // ---------------------------------------------------------------------------
 
-func checkAzureVMs(names []string, nameserver string, threads int, nameserverFile string) {
-	fmt.Println("[+] Checking for Azure Virtual Machines")
-	start := StartTimer()
-
-	regions := AzureRegions
-	fmt.Printf("[*] Testing across %d regions defined in the config file\n", len(regions))
-
-	for _, region := range regions {
-		var candidates []string
-		for _, n := range names {
-			candidates = append(candidates, n+"."+region+"."+vmURL)
-		}
-		FastDNSLookup(candidates, nameserver, nameserverFile, func(hostname string) {
-			FmtOutput(OutputData{
-				Platform: "azure",
-				Msg:      "Registered Azure Virtual Machine DNS Name",
-				Target:   hostname,
-				Access:   "public",
-			})
-		}, threads)
+// accountCheck builds a check that resolves the service's accounts and
+// probes the ones that exist.
+func accountCheck(name, service, title string) *check {
+	return &check{
+		name:     name,
+		provider: "azure",
+		service:  service,
+		title:    title,
+		build:    accountCandidates(service),
+		classify: ruleClassifier("azure-account", "azure"),
+		run:      dnsHTTPRun("http", true),
 	}
-
-	StopTimer(start)
 }
 
-// ---------------------------------------------------------------------------
-// RunAllAzure is the public entry-point called by main.
-// ---------------------------------------------------------------------------
-
-func RunAllAzure(names []string, cfg *Config) {
-	fmt.Print(azureBanner)
-
-	validAccounts := checkAzureAccountType(names, cfg.Threads, cfg.Nameserver, cfg.NameserverFile, blobURL, "Storage Accounts")
-	if len(validAccounts) > 0 && !cfg.QuickScan {
-		bruteForceContainers(validAccounts, cfg.BruteData, cfg.Threads)
-	}
-
-	checkAzureAccountType(names, cfg.Threads, cfg.Nameserver, cfg.NameserverFile, fileURL, "File Accounts")
-	checkAzureAccountType(names, cfg.Threads, cfg.Nameserver, cfg.NameserverFile, queueURL, "Queue Accounts")
-	checkAzureAccountType(names, cfg.Threads, cfg.Nameserver, cfg.NameserverFile, tableURL, "Table Accounts")
-	checkAzureAccountType(names, cfg.Threads, cfg.Nameserver, cfg.NameserverFile, mgmtURL, "App Management Accounts")
-	checkAzureAccountType(names, cfg.Threads, cfg.Nameserver, cfg.NameserverFile, vaultURL, "Key Vault Accounts")
-
-	checkAzureWebsites(names, cfg.Nameserver, cfg.Threads, cfg.NameserverFile)
-	checkAzureDatabases(names, cfg.Nameserver, cfg.Threads, cfg.NameserverFile)
-	checkAzureVMs(names, cfg.Nameserver, cfg.Threads, cfg.NameserverFile)
+func init() {
+	Register(accountCheck("azure-blob", "blob", "Azure Storage Accounts"))
+
+	Register(&check{
+		name:     "azure-containers",
+		provider: "azure",
+		service:  "blob",
+		title:    "Azure containers",
+		deps:     []string{"azure-blob"},
+		build:    containerCandidates,
+		classify: ruleClassifier("azure-containers", "azure"),
+		run: func(ctx context.Context, c *check, s *Scanner, accounts []string) ([]string, error) {
+			return nil, bruteForceContainers(ctx, s, accounts, c.classifier(s))
+		},
+	})
+
+	Register(accountCheck("azure-file", "file", "Azure File Accounts"))
+	Register(accountCheck("azure-queue", "queue", "Azure Queue Accounts"))
+	Register(accountCheck("azure-table", "table", "Azure Table Accounts"))
+	Register(accountCheck("azure-scm", "scm", "Azure App Management Accounts"))
+	Register(accountCheck("azure-vault", "vault", "Azure Key Vault Accounts"))
+
+	Register(&check{
+		name:     "azure-websites",
+		provider: "azure",
+		service:  "websites",
+		title:    "Azure Websites",
+		build:    hostCandidates("websites", nil),
+		resolved: printRegisteredName("Website"),
+		run:      dnsRun,
+	})
+	Register(&check{
+		name:     "azure-databases",
+		provider: "azure",
+		service:  "databases",
+		title:    "Azure Databases",
+		build:    hostCandidates("databases", nil),
+		resolved: printRegisteredName("Database"),
+		run:      dnsRun,
+	})
+	Register(&check{
+		name:     "azure-vms",
+		provider: "azure",
+		service:  "vms",
+		title:    "Azure Virtual Machines",
+		build:    hostCandidates("vms", AzureRegions),
+		resolved: printRegisteredName("Virtual Machine"),
+		run: func(ctx context.Context, c *check, s *Scanner, candidates []string) ([]string, error) {
+			s.Printf("[*] Testing across %d regions defined in the config file\n", len(AzureRegions))
+			return dnsRun(ctx, c, s, candidates)
+		},
+	})
 }
@@ -0,0 +1,280 @@
+package enum_tools
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Throttling detection
+// ---------------------------------------------------------------------------
+
+// maxThrottleRetries is how often a throttled candidate is re-queued
+// before it is given up on.
+const maxThrottleRetries = 6
+
+// throttleMarkers are the reason / body snippets of throttling 503s.
+var throttleMarkers = []string{
+	"Slow Down", "SlowDown", // S3
+	"ServerBusy", "Server Busy", "The server is busy", // Azure storage
+	"rateLimitExceeded", // GCS
+}
+
+// isThrottled reports whether a response is the provider asking us to slow
+// down, and how long it asked us to wait (0 when it didn't say).
+func isThrottled(result *HttpResult) (bool, time.Duration) {
+	retryAfter := parseRetryAfter(result.Header.Get("Retry-After"))
+	switch result.StatusCode {
+	case http.StatusTooManyRequests:
+		return true, retryAfter
+	case http.StatusServiceUnavailable:
+		if retryAfter > 0 {
+			return true, retryAfter
+		}
+		for _, m := range throttleMarkers {
+			if strings.Contains(result.Reason, m) || strings.Contains(result.Body, m) {
+				return true, 0
+			}
+		}
+	}
+	return false, 0
+}
+
+// throttledError is returned by requests made outside GetURLBatch when
+// the provider throttled them.
+type throttledError struct {
+	status     string
+	retryAfter time.Duration
+}
+
+func (e *throttledError) Error() string { return "throttled: " + e.status }
+
+// parseRetryAfter parses a Retry-After header (seconds or an HTTP date).
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// retryDelay is the exponential backoff (with jitter) before attempt n of
+// a throttled candidate, unless the provider asked for a delay itself.
+func retryDelay(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	d := 500 * time.Millisecond << min(attempt, 6)
+	d = min(d, 30*time.Second)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// ---------------------------------------------------------------------------
+// Adaptive concurrency
+// ---------------------------------------------------------------------------
+
+// adaptiveLimit bounds the number of requests in flight to one provider.
+// Throttling halves the limit (at most once per second, so one burst of
+// throttled replies counts once) and pauses new requests for Retry-After;
+// every limit successful requests raise it by one again, up to max.
+type adaptiveLimit struct {
+	mu         sync.Mutex
+	limit, max int
+	inflight   int
+	successes  int
+	lastCut    time.Time
+	pauseUntil time.Time
+	changed    chan struct{} // closed and replaced whenever a slot may have freed up
+}
+
+func newAdaptiveLimit(max int) *adaptiveLimit {
+	return &adaptiveLimit{limit: max, max: max, changed: make(chan struct{})}
+}
+
+// broadcast wakes every waiter. Must be called with mu held.
+func (a *adaptiveLimit) broadcast() {
+	close(a.changed)
+	a.changed = make(chan struct{})
+}
+
+// acquire waits for a free slot or until ctx is done.
+func (a *adaptiveLimit) acquire(ctx context.Context) error {
+	for {
+		a.mu.Lock()
+		pause := time.Until(a.pauseUntil)
+		if pause <= 0 && a.inflight < a.limit {
+			a.inflight++
+			a.mu.Unlock()
+			return nil
+		}
+		changed := a.changed
+		a.mu.Unlock()
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if pause > 0 {
+			timer = time.NewTimer(pause)
+			expired = timer.C
+		}
+		select {
+		case <-changed:
+		case <-expired:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// release frees a slot and adapts the limit to the response. Returns the
+// new limit when throttling lowered it, 0 otherwise.
+func (a *adaptiveLimit) release(throttled bool, retryAfter time.Duration) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	defer a.broadcast()
+	a.inflight--
+
+	if !throttled {
+		if a.successes++; a.successes >= a.limit && a.limit < a.max {
+			a.successes = 0
+			a.limit++
+		}
+		return 0
+	}
+	now := time.Now()
+	if retryAfter > 0 && now.Add(retryAfter).After(a.pauseUntil) {
+		a.pauseUntil = now.Add(retryAfter)
+	}
+	a.successes = 0
+	if now.Sub(a.lastCut) < time.Second {
+		return 0
+	}
+	a.lastCut = now
+	a.limit = max(1, a.limit/2)
+	return a.limit
+}
+
+// newAdaptiveLimits returns one adaptive limit per provider of checks,
+// so throttling by one provider doesn't slow down the others.
+func newAdaptiveLimits(checks []Check, threads int) map[string]*adaptiveLimit {
+	limits := make(map[string]*adaptiveLimit)
+	for _, c := range checks {
+		if _, ok := limits[c.Provider()]; !ok {
+			limits[c.Provider()] = newAdaptiveLimit(threads)
+		}
+	}
+	return limits
+}
+
+// ---------------------------------------------------------------------------
+// Retry queue
+// ---------------------------------------------------------------------------
+
+// urlJob is one GetURLBatch candidate.
+type urlJob struct {
+	idx     int
+	url     string
+	attempt int // throttled attempts so far
+}
+
+// retryQueue holds throttled candidates until their backoff has expired.
+type retryQueue struct {
+	mu     sync.Mutex
+	items  []retryItem
+	notify chan struct{} // signalled on push
+}
+
+type retryItem struct {
+	job urlJob
+	due time.Time
+}
+
+func newRetryQueue() *retryQueue {
+	return &retryQueue{notify: make(chan struct{}, 1)}
+}
+
+func (q *retryQueue) push(j urlJob, due time.Time) {
+	q.mu.Lock()
+	q.items = append(q.items, retryItem{j, due})
+	q.mu.Unlock()
+	q.wake()
+}
+
+// wake signals the consumer without blocking.
+func (q *retryQueue) wake() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pop returns a job whose backoff has expired. Otherwise it returns how
+// long until the next one is due (0 when the queue is empty).
+func (q *retryQueue) pop() (urlJob, bool, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var next time.Duration
+	now := time.Now()
+	for i, it := range q.items {
+		wait := it.due.Sub(now)
+		if wait <= 0 {
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			return it.job, true, 0
+		}
+		if next == 0 || wait < next {
+			next = wait
+		}
+	}
+	return urlJob{}, false, next
+}
+
+// ---------------------------------------------------------------------------
+// Statistics
+// ---------------------------------------------------------------------------
+
+// Stats counts what happened to a scanner's HTTP requests and lookups.
+type Stats struct {
+	Requests  int64 // HTTP requests sent
+	Throttled int64 // responses that asked us to slow down
+	Retried   int64 // throttled candidates re-queued
+	GaveUp    int64 // candidates dropped after maxThrottleRetries
+
+	Lookups        int64 // DNS names resolved
+	LookupFailures int64 // lookups that timed out or failed (SERVFAIL, ...)
+	CacheHits      int64 // names answered from the DNS cache
+}
+
+// scanStats holds the live counters behind Stats.
+type scanStats struct {
+	requests, throttled, retried, gaveUp atomic.Int64
+	lookups, lookupFailures, cacheHits   atomic.Int64
+}
+
+// Stats returns the scanner's request counters.
+func (s *Scanner) Stats() Stats {
+	return Stats{
+		Requests:  s.stats.requests.Load(),
+		Throttled: s.stats.throttled.Load(),
+		Retried:   s.stats.retried.Load(),
+		GaveUp:    s.stats.gaveUp.Load(),
+
+		Lookups:        s.stats.lookups.Load(),
+		LookupFailures: s.stats.lookupFailures.Load(),
+		CacheHits:      s.stats.cacheHits.Load(),
+	}
+}
@@ -0,0 +1,180 @@
+package enum_tools
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIsThrottled(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		reason     string
+		body       string
+		retryAfter string
+		throttled  bool
+		wait       time.Duration
+	}{
+		{"429", 429, "Too Many Requests", "", "", true, 0},
+		{"429 with Retry-After", 429, "Too Many Requests", "", "7", true, 7 * time.Second},
+		{"S3 Slow Down", 503, "Slow Down", "", "", true, 0},
+		{"S3 SlowDown body", 503, "Service Unavailable", "<Code>SlowDown</Code>", "", true, 0},
+		{"Azure ServerBusy", 503, "The server is busy.", "", "", true, 0},
+		{"GCS rateLimitExceeded", 503, "Service Unavailable", `{"reason": "rateLimitExceeded"}`, "", true, 0},
+		{"503 with Retry-After", 503, "Service Unavailable", "", "2", true, 2 * time.Second},
+		{"plain 503", 503, "Service Unavailable", "maintenance", "", false, 0},
+		{"200 saying Slow Down", 200, "OK", "SlowDown", "", false, 0},
+		{"403", 403, "Forbidden", "", "10", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &HttpResult{StatusCode: tt.status, Reason: tt.reason, Body: tt.body, Header: http.Header{}}
+			if tt.retryAfter != "" {
+				result.Header.Set("Retry-After", tt.retryAfter)
+			}
+			throttled, wait := isThrottled(result)
+			if throttled != tt.throttled || wait != tt.wait {
+				t.Errorf("got %v %v, want %v %v", throttled, wait, tt.throttled, tt.wait)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("30"); d != 30*time.Second {
+		t.Errorf("seconds: %v", d)
+	}
+	for _, v := range []string{"", "0", "-5", "soon", http.TimeFormat} {
+		if d := parseRetryAfter(v); d != 0 {
+			t.Errorf("%q: %v, want 0", v, d)
+		}
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if d := parseRetryAfter(date); d < 55*time.Second || d > time.Minute {
+		t.Errorf("date a minute ahead: %v", d)
+	}
+	past := time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)
+	if d := parseRetryAfter(past); d != 0 {
+		t.Errorf("date in the past: %v", d)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	if d := retryDelay(3, 5*time.Second); d != 5*time.Second {
+		t.Errorf("Retry-After not honoured: %v", d)
+	}
+	for attempt, ceiling := range map[int]time.Duration{
+		0:  500 * time.Millisecond,
+		1:  time.Second,
+		3:  4 * time.Second,
+		6:  30 * time.Second,
+		20: 30 * time.Second,
+	} {
+		for i := 0; i < 20; i++ {
+			if d := retryDelay(attempt, 0); d < ceiling/2 || d > ceiling {
+				t.Errorf("attempt %d: %v, want between %v and %v", attempt, d, ceiling/2, ceiling)
+			}
+		}
+	}
+}
+
+func TestAdaptiveLimit(t *testing.T) {
+	ctx := context.Background()
+	a := newAdaptiveLimit(4)
+	for i := 0; i < 4; i++ {
+		if err := a.acquire(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	full, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := a.acquire(full); err == nil {
+		t.Fatal("acquired a fifth slot of 4")
+	}
+
+	// A waiter gets the slot a release frees.
+	got := make(chan error)
+	go func() { got <- a.acquire(ctx) }()
+	a.release(false, 0)
+	if err := <-got; err != nil {
+		t.Fatal(err)
+	}
+
+	// Throttling halves the limit, once per burst.
+	if lowered := a.release(true, 0); lowered != 2 {
+		t.Errorf("first throttled release lowered to %d, want 2", lowered)
+	}
+	if lowered := a.release(true, 0); lowered != 0 || a.limit != 2 {
+		t.Errorf("second throttled release within a second: lowered to %d, limit %d", lowered, a.limit)
+	}
+	a.lastCut = time.Now().Add(-2 * time.Second)
+	if lowered := a.release(true, 0); lowered != 1 {
+		t.Errorf("throttled release a burst later lowered to %d, want 1", lowered)
+	}
+	if lowered := a.release(true, 0); lowered != 0 || a.limit != 1 {
+		t.Errorf("limit %d, want it kept at 1", a.limit)
+	}
+
+	// limit successes in a row raise it by one, up to max.
+	for want := 2; want <= 4; want++ {
+		for i := 0; i < want-1; i++ {
+			a.acquire(ctx)
+			a.release(false, 0)
+		}
+		if a.limit != want {
+			t.Fatalf("limit %d, want %d", a.limit, want)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		a.acquire(ctx)
+		a.release(false, 0)
+	}
+	if a.limit != 4 {
+		t.Errorf("limit %d past max 4", a.limit)
+	}
+}
+
+func TestAdaptiveLimitPause(t *testing.T) {
+	ctx := context.Background()
+	a := newAdaptiveLimit(4)
+	a.acquire(ctx)
+	a.release(true, 50*time.Millisecond)
+
+	start := time.Now()
+	if err := a.acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if took := time.Since(start); took < 40*time.Millisecond {
+		t.Errorf("acquired after %v, want the 50ms Retry-After pause", took)
+	}
+}
+
+func TestRetryQueue(t *testing.T) {
+	q := newRetryQueue()
+	if _, ok, next := q.pop(); ok || next != 0 {
+		t.Fatalf("empty queue: ok %v, next %v", ok, next)
+	}
+
+	now := time.Now()
+	q.push(urlJob{idx: 1}, now.Add(time.Hour))
+	q.push(urlJob{idx: 2}, now.Add(-time.Second))
+	q.push(urlJob{idx: 3}, now.Add(time.Minute))
+	select {
+	case <-q.notify:
+	default:
+		t.Error("push didn't notify")
+	}
+
+	if j, ok, _ := q.pop(); !ok || j.idx != 2 {
+		t.Fatalf("got job %d (%v), want the due job 2", j.idx, ok)
+	}
+	_, ok, next := q.pop()
+	if ok {
+		t.Fatal("popped a job before it was due")
+	}
+	if next <= 59*time.Second || next > time.Minute {
+		t.Errorf("next due in %v, want about a minute", next)
+	}
+}
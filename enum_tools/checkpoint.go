@@ -0,0 +1,348 @@
+package enum_tools
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ---------------------------------------------------------------------------
+// Checkpoint state
+// ---------------------------------------------------------------------------
+
+// checkpointVersion is bumped whenever the state file layout changes.
+const checkpointVersion = 2
+
+// checkpointState is the on-disk layout of a -resume state file.
+type checkpointState struct {
+	Version   int                    `json:"version"`
+	Hash      string                 `json:"hash"`      // ties the file to one scan
+	RunID     string                 `json:"run_id"`    // kept by resumed runs
+	Completed map[string][]string    `json:"completed"` // finished checks and their results
+	Batches   map[string]*batchState `json:"batches"`   // progress of unfinished batches
+	Values    map[string][]string    `json:"values"`    // intermediate results kept by checks
+	Findings  []Finding              `json:"findings"`
+}
+
+// batchState records how far a GetURLBatch / FastDNSLookup call got.
+type batchState struct {
+	Total int      `json:"total"`
+	Done  int      `json:"done"`            // candidates [0, Done) are finished
+	Found []string `json:"found,omitempty"` // DNS names found so far
+}
+
+// checkpoint holds the progress of one Run. When path is empty nothing is
+// persisted, but checks still use it to share intermediate results.
+type checkpoint struct {
+	path  string
+	mu    sync.Mutex
+	state checkpointState
+	dirty bool
+	seen  map[string]bool // findings already recorded
+}
+
+// scanHash identifies a scan by everything that shapes its candidate lists:
+// the mutated names (keywords × mutations), the brute-force list, the
+// selected checks, the region lists and the endpoint overrides, and by
+// what classifies the responses: the rules and the template definitions.
+func scanHash(names []string, cfg *Config, checks []Check) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d\n", checkpointVersion)
+	fmt.Fprintf(h, "names:%s\n", strings.Join(names, ","))
+	fmt.Fprintf(h, "brute:%s\n", cfg.BruteData)
+	fmt.Fprintf(h, "quickscan:%t\n", cfg.QuickScan)
+	for _, c := range checks {
+		fmt.Fprintf(h, "check:%s\n", c.Name())
+		if c, ok := c.(*check); ok && c.template != nil {
+			def, _ := json.Marshal(c.template)
+			fmt.Fprintf(h, "template:%s\n", def)
+		}
+	}
+	if cfg.Rules != nil {
+		rules, _ := json.Marshal(cfg.Rules.sets)
+		fmt.Fprintf(h, "rules:%s\n", rules)
+	}
+	fmt.Fprintf(h, "azure:%s\n", strings.Join(AzureRegions, ","))
+	fmt.Fprintf(h, "gcp:%s\n", strings.Join(GCPRegions, ","))
+	for _, service := range EndpointServices() {
+		if e, ok := cfg.Endpoints[service]; ok {
+			fmt.Fprintf(h, "endpoint:%s=%+v\n", service, e)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// loadCheckpoint opens (or starts) the state file at path. A file written
+// for a different scan is refused.
+func loadCheckpoint(path, hash string) (*checkpoint, error) {
+	cp := &checkpoint{
+		path: path,
+		state: checkpointState{
+			Version:   checkpointVersion,
+			Hash:      hash,
+			RunID:     newRunID(),
+			Completed: make(map[string][]string),
+			Batches:   make(map[string]*batchState),
+			Values:    make(map[string][]string),
+		},
+		seen: make(map[string]bool),
+	}
+	if path == "" {
+		return cp, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read state file: %w", err)
+	}
+
+	var st checkpointState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("state file %s is corrupt: %w", path, err)
+	}
+	if st.Version != checkpointVersion {
+		return nil, fmt.Errorf("state file %s has unsupported version %d", path, st.Version)
+	}
+	if st.Hash != hash {
+		return nil, fmt.Errorf("state file %s belongs to a different scan (keywords, mutations, rules, templates or config changed)", path)
+	}
+	if st.RunID != "" {
+		cp.state.RunID = st.RunID
+	}
+	if st.Completed != nil {
+		cp.state.Completed = st.Completed
+	}
+	if st.Batches != nil {
+		cp.state.Batches = st.Batches
+	}
+	if st.Values != nil {
+		cp.state.Values = st.Values
+	}
+	cp.state.Findings = st.Findings
+	for _, f := range st.Findings {
+		cp.seen[findingKey(f)] = true
+	}
+	return cp, nil
+}
+
+// newRunID returns a random ID for a new run.
+func newRunID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// save writes the state file atomically if anything changed.
+func (cp *checkpoint) save() error {
+	if cp.path == "" {
+		return nil
+	}
+	cp.mu.Lock()
+	if !cp.dirty {
+		cp.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(&cp.state)
+	cp.dirty = false
+	cp.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(cp.path, data)
+}
+
+// writeFileAtomic replaces path with data through a temporary file, so a
+// crash never leaves a truncated file behind.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".cloud_enum-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ---------------------------------------------------------------------------
+// Checks and findings
+// ---------------------------------------------------------------------------
+
+// completed returns the results of a check finished in a previous run.
+func (cp *checkpoint) completed(check string) ([]string, bool) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	results, ok := cp.state.Completed[check]
+	return results, ok
+}
+
+// complete marks a check as finished and drops its batch progress.
+func (cp *checkpoint) complete(check string, results []string) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if results == nil {
+		results = []string{}
+	}
+	cp.state.Completed[check] = results
+	for key := range cp.state.Batches {
+		if strings.HasPrefix(key, check+"/") {
+			delete(cp.state.Batches, key)
+		}
+	}
+	cp.dirty = true
+}
+
+func findingKey(f Finding) string {
+	return f.Check + "\x00" + f.Msg + "\x00" + f.Target
+}
+
+// recordFinding stores a finding. Returns false when the same finding was
+// already recorded, e.g. by the run being resumed.
+func (cp *checkpoint) recordFinding(f Finding) bool {
+	if cp == nil {
+		return true
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	key := findingKey(f)
+	if cp.seen[key] {
+		return false
+	}
+	cp.seen[key] = true
+	cp.state.Findings = append(cp.state.Findings, f)
+	cp.dirty = true
+	return true
+}
+
+// findings returns the findings recorded so far.
+func (cp *checkpoint) findings() []Finding {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	out := make([]Finding, len(cp.state.Findings))
+	copy(out, cp.state.Findings)
+	return out
+}
+
+// remember adds value to the named list of intermediate results. It is
+// a no-op outside of a Run.
+func (cp *checkpoint) remember(key, value string) {
+	if cp == nil {
+		return
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	for _, v := range cp.state.Values[key] {
+		if v == value {
+			return
+		}
+	}
+	cp.state.Values[key] = append(cp.state.Values[key], value)
+	cp.dirty = true
+}
+
+// recall returns the named list of intermediate results.
+func (cp *checkpoint) recall(key string) []string {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	out := make([]string, len(cp.state.Values[key]))
+	copy(out, cp.state.Values[key])
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Batch progress
+// ---------------------------------------------------------------------------
+
+// batch tracks the out-of-order completion of one batch's candidates so
+// the low-water mark in its batchState only ever covers finished work.
+type batch struct {
+	cp       *checkpoint
+	state    *batchState
+	start    int // candidates before this were finished in a previous run
+	finished []bool
+}
+
+// beginBatch returns the progress tracker for a batch of candidates run by
+// check. Batches are keyed by their candidate list, so they are matched up
+// on resume regardless of the order in which a check issues them. Returns
+// nil outside of a Run; the batch methods are no-ops on a nil batch.
+func (cp *checkpoint) beginBatch(check string, candidates []string) *batch {
+	if cp == nil {
+		return nil
+	}
+	h := sha256.Sum256([]byte(strings.Join(candidates, "\n")))
+	key := check + "/" + hex.EncodeToString(h[:8])
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	st, ok := cp.state.Batches[key]
+	if !ok || st.Total != len(candidates) {
+		st = &batchState{Total: len(candidates)}
+		cp.state.Batches[key] = st
+		cp.dirty = true
+	}
+	return &batch{
+		cp:       cp,
+		state:    st,
+		start:    st.Done,
+		finished: make([]bool, len(candidates)),
+	}
+}
+
+// markDone records that candidate i has been fully handled.
+func (b *batch) markDone(i int) {
+	if b == nil {
+		return
+	}
+	b.cp.mu.Lock()
+	defer b.cp.mu.Unlock()
+	b.finished[i] = true
+	for b.state.Done < len(b.finished) && (b.state.Done < b.start || b.finished[b.state.Done]) {
+		b.state.Done++
+	}
+	b.cp.dirty = true
+}
+
+// addFound records a name resolved by a DNS batch.
+func (b *batch) addFound(name string) {
+	if b == nil {
+		return
+	}
+	b.cp.mu.Lock()
+	defer b.cp.mu.Unlock()
+	for _, n := range b.state.Found {
+		if n == name {
+			return
+		}
+	}
+	b.state.Found = append(b.state.Found, name)
+	b.cp.dirty = true
+}
+
+// found returns the names resolved by this batch in a previous run.
+func (b *batch) found() []string {
+	if b == nil {
+		return nil
+	}
+	b.cp.mu.Lock()
+	defer b.cp.mu.Unlock()
+	out := make([]string, len(b.state.Found))
+	copy(out, b.state.Found)
+	return out
+}
@@ -0,0 +1,255 @@
+package enum_tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBatchLowWaterMark(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	cp, err := loadCheckpoint(path, "scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	candidates := []string{"a", "b", "c", "d", "e"}
+	b := cp.beginBatch("check", candidates)
+
+	// Candidates finish out of order; the mark only covers an unbroken
+	// run of finished ones from the start.
+	for _, step := range []struct{ done, want int }{
+		{2, 0}, {0, 1}, {4, 1}, {1, 3},
+	} {
+		b.markDone(step.done)
+		if b.state.Done != step.want {
+			t.Fatalf("after candidate %d: low-water mark %d, want %d", step.done, b.state.Done, step.want)
+		}
+	}
+	if err := cp.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Resumed, the batch starts at the mark: candidate 4 finished before,
+	// but past the mark, so it has to be run again.
+	cp, err = loadCheckpoint(path, "scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = cp.beginBatch("check", candidates)
+	if b.start != 3 {
+		t.Fatalf("resumed at %d, want 3", b.start)
+	}
+	b.markDone(4)
+	if b.state.Done != 3 {
+		t.Errorf("low-water mark %d, want 3 until candidate 3 finishes", b.state.Done)
+	}
+	b.markDone(3)
+	if b.state.Done != 5 {
+		t.Errorf("low-water mark %d, want 5", b.state.Done)
+	}
+
+	// Another candidate list is another batch.
+	if other := cp.beginBatch("check", candidates[:4]); other.start != 0 {
+		t.Errorf("different batch resumed at %d", other.start)
+	}
+	var nilBatch *batch
+	nilBatch.markDone(0) // outside of a Run
+}
+
+func TestLoadCheckpointMismatch(t *testing.T) {
+	rulesPath := filepath.Join(t.TempDir(), "rules.json")
+	err := os.WriteFile(rulesPath, []byte(`{"version": 1, "rulesets": {"aws-s3": [{"status": [418], "msg": "Teapot", "access": "public"}]}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userRules, err := LoadRules(rulesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := func(body string) Check {
+		tmpl := Template{Name: "custom-pages", Protocol: "https", Pattern: "{name}.example.net",
+			Matchers: []*rule{{Body: body, Msg: "Site", Access: "public"}}}
+		c, err := tmpl.check()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	names := []string{"acme", "acme-dev"}
+	cfg := &Config{Rules: DefaultRules()}
+	checks := []Check{template("Welcome")}
+	base := scanHash(names, cfg, checks)
+	if again := scanHash(names, &Config{Rules: DefaultRules()}, []Check{template("Welcome")}); again != base {
+		t.Fatal("the same scan hashes differently")
+	}
+
+	tests := []struct {
+		name  string
+		names []string
+		cfg   *Config
+		check Check
+	}{
+		{"names", []string{"acme"}, cfg, checks[0]},
+		{"brute-force list", names, &Config{Rules: DefaultRules(), BruteData: "api\n"}, checks[0]},
+		{"rules", names, &Config{Rules: userRules}, checks[0]},
+		{"template", names, cfg, template("Hello")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.json")
+			cp, err := loadCheckpoint(path, base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cp.complete("custom-pages", nil)
+			if err := cp.save(); err != nil {
+				t.Fatal(err)
+			}
+			hash := scanHash(tt.names, tt.cfg, []Check{tt.check})
+			if hash == base {
+				t.Fatal("same hash")
+			}
+			_, err = loadCheckpoint(path, hash)
+			if err == nil || !strings.Contains(err.Error(), "belongs to a different scan") {
+				t.Fatalf("error %v, want the state file refused", err)
+			}
+		})
+	}
+
+	t.Run("version", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+		data, _ := json.Marshal(checkpointState{Version: checkpointVersion - 1, Hash: base})
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadCheckpoint(path, base); err == nil || !strings.Contains(err.Error(), "unsupported version") {
+			t.Fatalf("error %v, want an unsupported version", err)
+		}
+	})
+}
+
+func TestResume(t *testing.T) {
+	sc, err := LoadScenario("../scenarios/demo.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim := NewSimulator(sc)
+	if err := sim.Start("127.0.0.1:0", "127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Close()
+	names := BuildNames([]string{"acme"}, []string{"backup", "dev", "prod"})
+	checks := []string{"aws-s3", "azure-blob", "gcp-buckets"}
+
+	// scan runs the checks with the state file at path, calling stop after
+	// each finding; the scan is cancelled once stop returns true.
+	scan := func(path string, stop func(Finding) bool) ([]Finding, Stats, string, error) {
+		t.Helper()
+		out := &syncBuffer{}
+		s, err := NewScanner(Config{
+			Checks:      checks,
+			Endpoints:   sim.Endpoints(),
+			DNSOverride: sim.DNSAddr(),
+			Output:      out,
+			StateFile:   path,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var mu sync.Mutex
+		var findings []Finding
+		err = s.Run(ctx, names, func(f Finding) {
+			mu.Lock()
+			defer mu.Unlock()
+			findings = append(findings, f)
+			if stop(f) {
+				cancel()
+			}
+		})
+		return findings, s.Stats(), out.String(), err
+	}
+	never := func(Finding) bool { return false }
+	keys := func(findings []Finding) []string {
+		var out []string
+		for _, f := range findings {
+			out = append(out, findingKey(f))
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	full, fullStats, _, err := scan("", never)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(full) == 0 || fullStats.Requests == 0 {
+		t.Fatalf("%d findings and %d requests without a state file", len(full), fullStats.Requests)
+	}
+
+	t.Run("finished", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+		first, _, _, err := scan(path, never)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, stats, _, err := scan(path, never)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := strings.Join(keys(again), "\n"), strings.Join(keys(first), "\n"); got != want {
+			t.Errorf("replayed\n%s\nwant\n%s", got, want)
+		}
+		for _, f := range again {
+			if !f.Restored || f.RunID != first[0].RunID {
+				t.Errorf("%s: restored %v, run %s, want restored from run %s", f.Target, f.Restored, f.RunID, first[0].RunID)
+			}
+		}
+		if stats.Requests != 0 || stats.Lookups != 0 {
+			t.Errorf("%d requests and %d lookups, want none", stats.Requests, stats.Lookups)
+		}
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+		first, firstStats, _, err := scan(path, func(f Finding) bool { return f.Check == "azure-blob" })
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("error %v, want the scan cancelled", err)
+		}
+		second, secondStats, out, err := scan(path, never)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, "Skipping aws-s3, completed in a previous run") {
+			t.Error("aws-s3 was run again")
+		}
+
+		var restored, fresh []Finding
+		for _, f := range second {
+			if f.Restored {
+				restored = append(restored, f)
+			} else {
+				fresh = append(fresh, f)
+			}
+		}
+		if got, want := strings.Join(keys(restored), "\n"), strings.Join(keys(first), "\n"); got != want {
+			t.Errorf("replayed\n%s\nwant\n%s", got, want)
+		}
+		// Between them, the runs report every finding once.
+		if got, want := strings.Join(keys(append(restored, fresh...)), "\n"), strings.Join(keys(full), "\n"); got != want {
+			t.Errorf("got\n%s\nwant\n%s", got, want)
+		}
+		if secondStats.Requests >= fullStats.Requests {
+			t.Errorf("resumed run sent %d requests, want fewer than the %d of a whole scan", secondStats.Requests, fullStats.Requests)
+		}
+		t.Logf("requests: %d + %d, whole scan %d", firstStats.Requests, secondStats.Requests, fullStats.Requests)
+	})
+}
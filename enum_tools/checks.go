@@ -0,0 +1,376 @@
+package enum_tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Check interface
+// ---------------------------------------------------------------------------
+
+// Check is a single enumeration step (S3 buckets, Azure websites, ...).
+// Built-in checks register themselves from init(); in-house checks can do
+// the same with Register without touching the provider files.
+type Check interface {
+	// Name is the unique identifier used to select the check, e.g. "aws-s3".
+	Name() string
+	// Provider is the platform the check belongs to ("aws", "azure", "gcp").
+	Provider() string
+	// Dependencies lists the checks whose results this check consumes.
+	Dependencies() []string
+	// Candidates builds the hostnames / URLs to probe from the mutated
+	// names and the targets returned by the check's dependencies.
+	Candidates(names []string, cfg *Config, deps map[string][]string) []string
+	// Classify handles one HTTP response, reporting findings through the
+	// scanner. Returns true to break out.
+	Classify(s *Scanner, result *HttpResult) bool
+	// Run probes the candidates and returns the targets that exist. It
+	// stops feeding new work once ctx is done and returns ctx.Err().
+	Run(ctx context.Context, s *Scanner, candidates []string) ([]string, error)
+}
+
+// ---------------------------------------------------------------------------
+// Generic check implementation used by the built-in modules
+// ---------------------------------------------------------------------------
+
+// check is the Check implementation shared by the provider files. Only the
+// fields relevant to the check's probe style need to be set.
+type check struct {
+	name     string
+	provider string
+	service  string    // key into Config.Endpoints
+	title    string    // printed as "[+] Checking for <title>"
+	deps     []string  // names of checks that must run first
+	optIn    bool      // only runs when selected explicitly
+	template *Template // definition of a template check
+
+	build    func(names []string, cfg *Config, deps map[string][]string) []string
+	classify func(s *Scanner, result *HttpResult) bool // HTTP response classifier
+	resolved func(s *Scanner, ans *DNSAnswer)          // DNS classifier, called per valid name
+	run      func(ctx context.Context, c *check, s *Scanner, candidates []string) ([]string, error)
+}
+
+func (c *check) Name() string           { return c.name }
+func (c *check) Provider() string       { return c.provider }
+func (c *check) Dependencies() []string { return c.deps }
+
+func (c *check) Candidates(names []string, cfg *Config, deps map[string][]string) []string {
+	if c.build == nil {
+		return nil
+	}
+	return c.build(names, cfg, deps)
+}
+
+func (c *check) Classify(s *Scanner, result *HttpResult) bool {
+	if c.classify == nil {
+		return false
+	}
+	return c.classify(s, result)
+}
+
+func (c *check) Run(ctx context.Context, s *Scanner, candidates []string) ([]string, error) {
+	s.Printf("[+] Checking for %s\n", c.title)
+	start := StartTimer()
+	found, err := c.run(ctx, c, s, candidates)
+	s.stopTimer(start)
+	return found, err
+}
+
+// classifier binds a check's classifier to a scanner for GetURLBatch.
+func (c *check) classifier(s *Scanner) func(*HttpResult) bool {
+	return func(result *HttpResult) bool { return c.Classify(s, result) }
+}
+
+// resolvedCallback binds a check's DNS classifier to a scanner for
+// FastDNSLookup.
+func (c *check) resolvedCallback(s *Scanner) func(*DNSAnswer) {
+	if c.resolved == nil {
+		return nil
+	}
+	return func(ans *DNSAnswer) { c.resolved(s, ans) }
+}
+
+// httpRun probes every candidate URL and hands the responses to the
+// check's classifier.
+func httpRun(followRedirects bool) func(context.Context, *check, *Scanner, []string) ([]string, error) {
+	return func(ctx context.Context, c *check, s *Scanner, candidates []string) ([]string, error) {
+		return nil, s.GetURLBatch(ctx, candidates, false, c.classifier(s), followRedirects)
+	}
+}
+
+// dnsRun resolves every candidate and reports the ones that exist.
+func dnsRun(ctx context.Context, c *check, s *Scanner, candidates []string) ([]string, error) {
+	return s.FastDNSLookup(ctx, candidates, c.resolvedCallback(s))
+}
+
+// dnsHTTPRun resolves every candidate hostname first and only probes the
+// ones that exist, over the check's endpoint. Returns the de-duplicated
+// list of valid hostnames.
+func dnsHTTPRun(defaultScheme string, followRedirects bool) func(context.Context, *check, *Scanner, []string) ([]string, error) {
+	return func(ctx context.Context, c *check, s *Scanner, candidates []string) ([]string, error) {
+		validNames, err := s.FastDNSLookup(ctx, candidates, nil)
+		if err != nil {
+			return nil, err
+		}
+		validNames = dedupe(validNames)
+		ep := s.cfg.endpoint(c.service)
+		var urls []string
+		for _, host := range validNames {
+			urls = append(urls, ep.URLForHost(host, "", defaultScheme))
+		}
+		err = s.GetURLBatch(ctx, urls, false, c.classifier(s), followRedirects)
+		return validNames, err
+	}
+}
+
+// hostCandidates returns a builder expanding the service's host template
+// for every name (and every region, for regional services).
+func hostCandidates(service string, regions []string) func([]string, *Config, map[string][]string) []string {
+	if len(regions) == 0 {
+		regions = []string{""}
+	}
+	return func(names []string, cfg *Config, _ map[string][]string) []string {
+		ep := cfg.endpoint(service)
+		var candidates []string
+		for _, region := range regions {
+			for _, name := range names {
+				candidates = append(candidates, ep.Hostname(name, region))
+			}
+		}
+		return candidates
+	}
+}
+
+// urlCandidates returns a builder producing the URL of every name on the
+// service's endpoint, with path appended.
+func urlCandidates(service, defaultScheme, path string) func([]string, *Config, map[string][]string) []string {
+	return func(names []string, cfg *Config, _ map[string][]string) []string {
+		ep := cfg.endpoint(service)
+		var candidates []string
+		for _, name := range names {
+			candidates = append(candidates, ep.URL(name, "", path, defaultScheme))
+		}
+		return candidates
+	}
+}
+
+func dedupe(list []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
+
+// ---------------------------------------------------------------------------
+// Registry
+// ---------------------------------------------------------------------------
+
+var registry []Check
+
+var providerBanners = map[string]string{
+	"aws":    awsBanner,
+	"azure":  azureBanner,
+	"gcp":    gcpBanner,
+	"custom": customBanner,
+}
+
+// optInCheck is implemented by checks that only run when selected by name.
+type optInCheck interface {
+	OptIn() bool
+}
+
+func (c *check) OptIn() bool { return c.optIn }
+
+// Register adds a check to the registry. Checks run in registration order
+// unless their dependencies force otherwise. Panics on duplicate names.
+func Register(c Check) {
+	if _, ok := LookupCheck(c.Name()); ok {
+		panic("enum_tools: duplicate check " + c.Name())
+	}
+	registry = append(registry, c)
+}
+
+// Checks returns every registered check in registration order.
+func Checks() []Check {
+	out := make([]Check, len(registry))
+	copy(out, registry)
+	return out
+}
+
+// LookupCheck returns the registered check with the given name.
+func LookupCheck(name string) (Check, bool) {
+	for _, c := range registry {
+		if c.Name() == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// IsOptIn reports whether a check only runs when selected explicitly.
+func IsOptIn(c Check) bool {
+	o, ok := c.(optInCheck)
+	return ok && o.OptIn()
+}
+
+// matchSelector reports whether a -checks / -skip entry selects c. Entries
+// are check names or provider names.
+func matchSelector(c Check, sel string) bool {
+	return sel == c.Name() || sel == c.Provider()
+}
+
+// SelectChecks resolves include / exclude lists (check or provider names)
+// into an ordered list of checks. An empty include list selects every
+// check that isn't opt-in. Dependencies are pulled in automatically unless
+// they were excluded, in which case their dependents are dropped too.
+func SelectChecks(include, exclude []string) ([]Check, error) {
+	for _, sel := range append(append([]string{}, include...), exclude...) {
+		known := false
+		for _, c := range registry {
+			if matchSelector(c, sel) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown check or provider %q", sel)
+		}
+	}
+
+	excluded := func(c Check) bool {
+		for _, sel := range exclude {
+			if matchSelector(c, sel) {
+				return true
+			}
+		}
+		return false
+	}
+
+	selected := make(map[string]bool)
+	for _, c := range registry {
+		if len(include) == 0 {
+			selected[c.Name()] = !IsOptIn(c)
+			continue
+		}
+		for _, sel := range include {
+			// Opt-in checks must be named explicitly.
+			if sel == c.Name() || (sel == c.Provider() && !IsOptIn(c)) {
+				selected[c.Name()] = true
+			}
+		}
+	}
+
+	// Pull in dependencies.
+	var addDeps func(c Check) error
+	addDeps = func(c Check) error {
+		for _, dep := range c.Dependencies() {
+			d, ok := LookupCheck(dep)
+			if !ok {
+				return fmt.Errorf("check %q depends on unknown check %q", c.Name(), dep)
+			}
+			if !selected[dep] {
+				selected[dep] = true
+				if err := addDeps(d); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for _, c := range registry {
+		if selected[c.Name()] {
+			if err := addDeps(c); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Drop excluded checks and, transitively, anything depending on them.
+	for _, c := range registry {
+		if excluded(c) {
+			selected[c.Name()] = false
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, c := range registry {
+			if !selected[c.Name()] {
+				continue
+			}
+			for _, dep := range c.Dependencies() {
+				if !selected[dep] {
+					selected[c.Name()] = false
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	return orderChecks(selected)
+}
+
+// orderChecks returns the selected checks in registration order, moving a
+// check after its dependencies when needed.
+func orderChecks(selected map[string]bool) ([]Check, error) {
+	var ordered []Check
+	placed := make(map[string]bool)
+	visiting := make(map[string]bool)
+
+	var visit func(c Check) error
+	visit = func(c Check) error {
+		if placed[c.Name()] {
+			return nil
+		}
+		if visiting[c.Name()] {
+			return fmt.Errorf("dependency cycle involving check %q", c.Name())
+		}
+		visiting[c.Name()] = true
+		for _, dep := range c.Dependencies() {
+			d, _ := LookupCheck(dep)
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		visiting[c.Name()] = false
+		placed[c.Name()] = true
+		ordered = append(ordered, c)
+		return nil
+	}
+
+	for _, c := range registry {
+		if selected[c.Name()] {
+			if err := visit(c); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return ordered, nil
+}
+
+// ---------------------------------------------------------------------------
+// Listing
+// ---------------------------------------------------------------------------
+
+// ListChecks returns a human-readable table of registered checks.
+func ListChecks() string {
+	var b strings.Builder
+	for _, c := range registry {
+		line := fmt.Sprintf("  %-22s %-6s", c.Name(), c.Provider())
+		if deps := c.Dependencies(); len(deps) > 0 {
+			line += " needs: " + strings.Join(deps, ", ")
+		}
+		if IsOptIn(c) {
+			line += " (opt-in)"
+		}
+		b.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,671 @@
+package enum_tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// DNS answers
+// ---------------------------------------------------------------------------
+
+// DNSAnswer is what resolving one name returned.
+type DNSAnswer struct {
+	Name   string   `json:"name"`
+	CNAMEs []string `json:"cnames,omitempty"` // CNAME chain followed, in order
+	IPs    []string `json:"ips,omitempty"`    // A / AAAA records at the end of the chain
+	Rcode  string   `json:"rcode"`            // NOERROR, NXDOMAIN, SERVFAIL, ... or TIMEOUT
+	Server string   `json:"server,omitempty"` // nameserver that answered
+	TTL    uint32   `json:"ttl"`              // seconds the answer may be cached
+}
+
+// Found reports whether the name exists: it resolves to an address, or is
+// an alias of a name that exists.
+func (a *DNSAnswer) Found() bool {
+	return a.Rcode == "NOERROR" && (len(a.IPs) > 0 || len(a.CNAMEs) > 0)
+}
+
+// Target is the last name of the CNAME chain, or the name itself.
+func (a *DNSAnswer) Target() string {
+	if len(a.CNAMEs) > 0 {
+		return a.CNAMEs[len(a.CNAMEs)-1]
+	}
+	return a.Name
+}
+
+// ErrNameserver is returned when the nameservers refuse to serve us, which
+// makes every other answer meaningless.
+var ErrNameserver = errors.New("error querying nameservers")
+
+// dnsResolver resolves a name and its CNAME chain.
+type dnsResolver interface {
+	resolve(ctx context.Context, name string) (*DNSAnswer, error)
+}
+
+// ---------------------------------------------------------------------------
+// Wire format (RFC 1035, EDNS0 per RFC 6891)
+// ---------------------------------------------------------------------------
+
+const (
+	dnsTypeA     = 1
+	dnsTypeNS    = 2
+	dnsTypeCNAME = 5
+	dnsTypeSOA   = 6
+	dnsTypeAAAA  = 28
+	dnsTypeOPT   = 41
+
+	dnsClassIN = 1
+
+	rcodeNoError  = 0
+	rcodeFormErr  = 1
+	rcodeServFail = 2
+	rcodeNXDomain = 3
+	rcodeNotImp   = 4
+	rcodeRefused  = 5
+
+	ednsUDPSize = 1232 // avoids IP fragmentation, per DNS flag day 2020
+)
+
+var rcodeNames = map[int]string{
+	rcodeNoError:  "NOERROR",
+	rcodeFormErr:  "FORMERR",
+	rcodeServFail: "SERVFAIL",
+	rcodeNXDomain: "NXDOMAIN",
+	rcodeNotImp:   "NOTIMP",
+	rcodeRefused:  "REFUSED",
+}
+
+// RcodeName returns the mnemonic of a DNS response code.
+func RcodeName(rcode int) string {
+	if name, ok := rcodeNames[rcode]; ok {
+		return name
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
+// RcodeByName is the inverse of RcodeName.
+func RcodeByName(name string) (int, bool) {
+	for rcode, n := range rcodeNames {
+		if strings.EqualFold(n, name) {
+			return rcode, true
+		}
+	}
+	return 0, false
+}
+
+// dnsRR is one resource record. Target is set for CNAME and NS records.
+type dnsRR struct {
+	Name   string
+	Type   uint16
+	TTL    uint32
+	Data   []byte
+	Target string
+}
+
+// dnsMsg is a parsed DNS message.
+type dnsMsg struct {
+	ID        uint16
+	Response  bool
+	Truncated bool
+	RD        bool
+	Rcode     int
+	QName     string
+	QType     uint16
+	Answers   []dnsRR
+	Authority []dnsRR
+	EDNS      bool
+}
+
+// appendWireName appends name in wire format (without compression).
+func appendWireName(b []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				return nil, fmt.Errorf("invalid DNS name %q", name)
+			}
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+	return append(b, 0), nil
+}
+
+// buildQuery encodes a query for name, recursive when rd is set, with an
+// EDNS0 OPT record advertising udpSize unless it is 0.
+func buildQuery(id uint16, name string, qtype uint16, rd bool, udpSize uint16) ([]byte, error) {
+	b := make([]byte, 12, 64)
+	binary.BigEndian.PutUint16(b, id)
+	if rd {
+		binary.BigEndian.PutUint16(b[2:], 0x0100)
+	}
+	binary.BigEndian.PutUint16(b[4:], 1)
+	var err error
+	if b, err = appendWireName(b, name); err != nil {
+		return nil, err
+	}
+	b = binary.BigEndian.AppendUint16(b, qtype)
+	b = binary.BigEndian.AppendUint16(b, dnsClassIN)
+	if udpSize > 0 {
+		binary.BigEndian.PutUint16(b[10:], 1)
+		b = append(b, 0) // root
+		b = binary.BigEndian.AppendUint16(b, dnsTypeOPT)
+		b = binary.BigEndian.AppendUint16(b, udpSize)
+		b = binary.BigEndian.AppendUint32(b, 0)
+		b = binary.BigEndian.AppendUint16(b, 0)
+	}
+	return b, nil
+}
+
+// appendRR appends a resource record to a message being built.
+func appendRR(b []byte, rr dnsRR) ([]byte, error) {
+	var err error
+	if b, err = appendWireName(b, rr.Name); err != nil {
+		return nil, err
+	}
+	data := rr.Data
+	if rr.Type == dnsTypeCNAME || rr.Type == dnsTypeNS {
+		if data, err = appendWireName(nil, rr.Target); err != nil {
+			return nil, err
+		}
+	}
+	b = binary.BigEndian.AppendUint16(b, rr.Type)
+	b = binary.BigEndian.AppendUint16(b, dnsClassIN)
+	b = binary.BigEndian.AppendUint32(b, rr.TTL)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...), nil
+}
+
+var errDNSFormat = errors.New("malformed DNS message")
+
+// readName decodes a possibly compressed name at off. Returns the name and
+// the offset just past it.
+func readName(b []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1
+	for jumps := 0; ; {
+		if off >= len(b) {
+			return "", 0, errDNSFormat
+		}
+		l := int(b[off])
+		switch {
+		case l == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.ToLower(strings.Join(labels, ".")), end, nil
+		case l&0xc0 == 0xc0:
+			if off+1 >= len(b) || jumps > 16 {
+				return "", 0, errDNSFormat
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(b[off:]) & 0x3fff)
+			jumps++
+		case l > 63 || off+1+l > len(b):
+			return "", 0, errDNSFormat
+		default:
+			labels = append(labels, string(b[off+1:off+1+l]))
+			off += 1 + l
+		}
+	}
+}
+
+// parseMsg decodes a DNS message. Additional records other than OPT are
+// skipped.
+func parseMsg(b []byte) (*dnsMsg, error) {
+	if len(b) < 12 {
+		return nil, errDNSFormat
+	}
+	flags := binary.BigEndian.Uint16(b[2:])
+	m := &dnsMsg{
+		ID:        binary.BigEndian.Uint16(b),
+		Response:  flags&0x8000 != 0,
+		Truncated: flags&0x0200 != 0,
+		RD:        flags&0x0100 != 0,
+		Rcode:     int(flags & 0xf),
+	}
+	qd := int(binary.BigEndian.Uint16(b[4:]))
+	counts := []int{
+		int(binary.BigEndian.Uint16(b[6:])),
+		int(binary.BigEndian.Uint16(b[8:])),
+		int(binary.BigEndian.Uint16(b[10:])),
+	}
+	off := 12
+	for i := 0; i < qd; i++ {
+		name, next, err := readName(b, off)
+		if err != nil || next+4 > len(b) {
+			return nil, errDNSFormat
+		}
+		if i == 0 {
+			m.QName, m.QType = name, binary.BigEndian.Uint16(b[next:])
+		}
+		off = next + 4
+	}
+	for section, n := range counts {
+		for i := 0; i < n; i++ {
+			name, next, err := readName(b, off)
+			if err != nil || next+10 > len(b) {
+				return nil, errDNSFormat
+			}
+			rr := dnsRR{
+				Name: name,
+				Type: binary.BigEndian.Uint16(b[next:]),
+				TTL:  binary.BigEndian.Uint32(b[next+4:]),
+			}
+			if rr.Type == dnsTypeOPT {
+				// The OPT "class" carries the payload size and the TTL
+				// the extended rcode bits.
+				m.EDNS = true
+				m.Rcode |= int(rr.TTL>>24) << 4
+			}
+			rdlen := int(binary.BigEndian.Uint16(b[next+8:]))
+			start := next + 10
+			if start+rdlen > len(b) {
+				return nil, errDNSFormat
+			}
+			rr.Data = b[start : start+rdlen]
+			if rr.Type == dnsTypeCNAME || rr.Type == dnsTypeNS {
+				if rr.Target, _, err = readName(b, start); err != nil {
+					return nil, err
+				}
+			}
+			off = start + rdlen
+			switch section {
+			case 0:
+				m.Answers = append(m.Answers, rr)
+			case 1:
+				m.Authority = append(m.Authority, rr)
+			}
+		}
+	}
+	return m, nil
+}
+
+// ---------------------------------------------------------------------------
+// Client
+// ---------------------------------------------------------------------------
+
+// dnsClient sends queries to a list of recursive nameservers in rotation.
+// Classic ones are asked over UDP with a TCP retry for truncated replies,
+// or over TCP through a SOCKS proxy.
+type dnsClient struct {
+	servers []*nameserver
+	socks   *socksDialer
+	timeout time.Duration // per exchange
+
+	// Set for a zone's authoritative servers: queries are not recursive,
+	// and CNAME chains are only followed inside the zone.
+	norecurse bool
+	zone      string
+
+	evictMu sync.Mutex
+	warn    func(format string, a ...any) // reports evictions
+}
+
+// newDNSClient returns a client for nameservers in any form accepted by
+// parseNameserver.
+func newDNSClient(nameservers []string, socks *socksDialer, insecure bool, timeout time.Duration) (*dnsClient, error) {
+	c := &dnsClient{socks: socks, timeout: timeout}
+	for _, spec := range nameservers {
+		ns, err := parseNameserver(spec, socks, insecure)
+		if err != nil {
+			return nil, err
+		}
+		c.servers = append(c.servers, ns)
+	}
+	return c, nil
+}
+
+// resolve looks up name's A records, or its AAAA records when it has
+// none, following CNAME chains.
+func (c *dnsClient) resolve(ctx context.Context, name string) (*DNSAnswer, error) {
+	ans := &DNSAnswer{Name: strings.ToLower(strings.TrimSuffix(name, ".")), TTL: math.MaxUint32}
+	defer func() {
+		if ans.TTL == math.MaxUint32 {
+			ans.TTL = 0
+		}
+	}()
+	for _, qtype := range []uint16{dnsTypeA, dnsTypeAAAA} {
+		if err := c.follow(ctx, ans, qtype); err != nil {
+			return ans, err
+		}
+		if ans.Rcode != "NOERROR" || len(ans.IPs) > 0 {
+			break
+		}
+	}
+	return ans, nil
+}
+
+// maxCNAMEs bounds the CNAME chains followed.
+const maxCNAMEs = 8
+
+// follow queries qtype records for ans.Name and walks the CNAME chain,
+// asking again for the chain's target when a reply stops half-way.
+func (c *dnsClient) follow(ctx context.Context, ans *DNSAnswer, qtype uint16) error {
+	target := ans.Target()
+	for queries := 0; queries < maxCNAMEs; queries++ {
+		if c.zone != "" && !inZone(target, c.zone) {
+			return nil
+		}
+		msg, server, err := c.exchange(ctx, target, qtype)
+		ans.Server = server
+		if err != nil {
+			ans.Rcode = "TIMEOUT"
+			return err
+		}
+		ans.Rcode = RcodeName(msg.Rcode)
+		start := target
+		for len(ans.CNAMEs) < maxCNAMEs {
+			i := slices.IndexFunc(msg.Answers, func(rr dnsRR) bool {
+				return rr.Type == dnsTypeCNAME && rr.Name == target
+			})
+			if i < 0 {
+				break
+			}
+			target = msg.Answers[i].Target
+			ans.TTL = min(ans.TTL, msg.Answers[i].TTL)
+			if !slices.Contains(ans.CNAMEs, target) {
+				ans.CNAMEs = append(ans.CNAMEs, target)
+			}
+		}
+		// The chain is kept on NXDOMAIN too: a CNAME to a missing name
+		// is what a dangling record looks like.
+		if msg.Rcode != rcodeNoError {
+			ans.TTL = min(ans.TTL, negativeTTL(msg))
+			return nil
+		}
+		for _, rr := range msg.Answers {
+			if rr.Type != qtype || rr.Name != target {
+				continue
+			}
+			if ip := net.IP(rr.Data); len(ip) == net.IPv4len || len(ip) == net.IPv6len {
+				ans.IPs = append(ans.IPs, ip.String())
+				ans.TTL = min(ans.TTL, rr.TTL)
+			}
+		}
+		if len(ans.IPs) > 0 || target == start {
+			if len(ans.IPs) == 0 {
+				ans.TTL = min(ans.TTL, negativeTTL(msg)) // NODATA
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// inZone reports whether name is zone or below it.
+func inZone(name, zone string) bool {
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
+
+// negativeTTL is how long a negative answer may be cached: the SOA
+// record's minimum, capped by its own TTL (RFC 2308).
+func negativeTTL(msg *dnsMsg) uint32 {
+	for _, rr := range msg.Authority {
+		if rr.Type == dnsTypeSOA && len(rr.Data) >= 20 {
+			return min(rr.TTL, binary.BigEndian.Uint32(rr.Data[len(rr.Data)-4:]))
+		}
+	}
+	return defaultNegativeTTL
+}
+
+// exchange sends one query, moving on to another nameserver on timeouts
+// and server failures. Returns the last reply when no server did better.
+// A query sent is waited for even if ctx is cancelled meanwhile; ctx
+// only stops the next one.
+func (c *dnsClient) exchange(ctx context.Context, name string, qtype uint16) (*dnsMsg, string, error) {
+	var last *dnsMsg
+	var lastServer string
+	var lastErr error
+	var tried []*nameserver
+	for attempt := 0; attempt < max(2, min(len(c.servers), 3)); attempt++ {
+		ns := c.pick(tried)
+		if ns == nil {
+			lastErr = errNoNameservers
+			break
+		}
+		tried = append(tried, ns)
+		server := ns.String()
+		if err := ns.limit.wait(ctx); err != nil {
+			lastErr = err
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			lastErr = err
+			break
+		}
+		inflight := context.WithoutCancel(ctx)
+		start := time.Now()
+		msg, err := c.exchangeWith(inflight, ns, name, qtype)
+		c.record(inflight, ns, time.Since(start), msg, err)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		last, lastServer = msg, server
+		if msg.Rcode != rcodeServFail && msg.Rcode != rcodeRefused && msg.Rcode != rcodeNotImp {
+			break
+		}
+	}
+	if last != nil {
+		return last, lastServer, nil
+	}
+	return nil, lastServer, lastErr
+}
+
+// exchangeWith queries one nameserver, retrying over TCP when the UDP
+// reply was truncated and without EDNS when the server doesn't support it.
+func (c *dnsClient) exchangeWith(ctx context.Context, ns *nameserver, name string, qtype uint16) (*dnsMsg, error) {
+	udpSize := uint16(ednsUDPSize)
+	tcp := c.socks != nil
+	for {
+		id := uint16(rand.Uint32())
+		if ns.proto == "https" {
+			id = 0 // cache friendly, per RFC 8484
+		}
+		query, err := buildQuery(id, name, qtype, !c.norecurse, udpSize)
+		if err != nil {
+			return nil, err
+		}
+		var msg *dnsMsg
+		switch {
+		case ns.proto == "https":
+			msg, err = c.exchangeDoH(ctx, ns, query)
+		case ns.proto == "tls":
+			msg, err = c.exchangeDoT(ctx, ns, query)
+		case tcp:
+			msg, err = c.exchangeTCP(ctx, ns.addr, query)
+		default:
+			msg, err = c.exchangeUDP(ctx, ns.addr, query)
+		}
+		switch {
+		case err != nil:
+			return nil, err
+		case msg.Truncated && !tcp && ns.proto == "udp":
+			tcp = true
+		case msg.Rcode == rcodeFormErr && udpSize > 0:
+			udpSize = 0
+		default:
+			return msg, nil
+		}
+	}
+}
+
+// deadline bounds one exchange by the client timeout and ctx.
+func (c *dnsClient) deadline(ctx context.Context) time.Time {
+	deadline := time.Now().Add(c.timeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	return deadline
+}
+
+func (c *dnsClient) exchangeUDP(ctx context.Context, server string, query []byte) (*dnsMsg, error) {
+	d := net.Dialer{Deadline: c.deadline(ctx)}
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(c.deadline(ctx))
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, ednsUDPSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// Replies that don't match the query are ignored, not trusted.
+		if msg, err := parseMsg(buf[:n]); err == nil && isReplyTo(msg, query) {
+			return msg, nil
+		}
+	}
+}
+
+func (c *dnsClient) exchangeTCP(ctx context.Context, server string, query []byte) (*dnsMsg, error) {
+	var conn net.Conn
+	var err error
+	if c.socks != nil {
+		dctx, cancel := context.WithDeadline(ctx, c.deadline(ctx))
+		conn, err = c.socks.DialContext(dctx, "tcp", server)
+		cancel()
+	} else {
+		d := net.Dialer{Deadline: c.deadline(ctx)}
+		conn, err = d.DialContext(ctx, "tcp", server)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(c.deadline(ctx))
+	return tcpExchange(conn, query)
+}
+
+// tcpExchange sends a length-prefixed query on conn and reads the reply.
+func tcpExchange(conn io.ReadWriter, query []byte) (*dnsMsg, error) {
+	if _, err := conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(query))), query...)); err != nil {
+		return nil, err
+	}
+	var size [2]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return nil, err
+	}
+	reply := make([]byte, binary.BigEndian.Uint16(size[:]))
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, err
+	}
+	msg, err := parseMsg(reply)
+	if err != nil {
+		return nil, err
+	}
+	if !isReplyTo(msg, query) {
+		return nil, errors.New("DNS reply doesn't match the query")
+	}
+	return msg, nil
+}
+
+// isReplyTo checks a reply's ID and question against the query's.
+func isReplyTo(msg *dnsMsg, query []byte) bool {
+	q, err := parseMsg(query)
+	return err == nil && msg.Response && msg.ID == q.ID &&
+		(msg.QName == "" || msg.QName == q.QName && msg.QType == q.QType)
+}
+
+// ---------------------------------------------------------------------------
+// System resolver
+// ---------------------------------------------------------------------------
+
+// systemNameservers returns the nameservers of /etc/resolv.conf, if any.
+// The rest of the file is ignored: candidates are fully qualified, so
+// search domains must not be appended to them, and the options (timeout,
+// attempts, rotate, ndots, ...) give way to the client's own timeouts,
+// retries and server selection.
+func systemNameservers() []string {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var servers []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" && net.ParseIP(fields[1]) != nil {
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers
+}
+
+// systemResolver falls back to the platform resolver where there is no
+// resolv.conf. It only sees the final CNAME target and can't tell
+// resolver failures apart.
+type systemResolver struct {
+	timeout time.Duration
+}
+
+func (r systemResolver) resolve(ctx context.Context, name string) (*DNSAnswer, error) {
+	if err := ctx.Err(); err != nil {
+		return &DNSAnswer{Name: name, Rcode: "TIMEOUT"}, err
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.timeout)
+	defer cancel()
+	ans := &DNSAnswer{Name: name, Rcode: "NOERROR", TTL: 60}
+	addrs, err := net.DefaultResolver.LookupHost(ctx, name)
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		ans.IPs = addrs
+		if cname, err := net.DefaultResolver.LookupCNAME(ctx, name); err == nil {
+			if cname = strings.TrimSuffix(strings.ToLower(cname), "."); cname != name {
+				ans.CNAMEs = []string{cname}
+			}
+		}
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		ans.Rcode = "NXDOMAIN"
+	case errors.As(err, &dnsErr) && dnsErr.IsTimeout:
+		ans.Rcode = "TIMEOUT"
+	default:
+		ans.Rcode = "SERVFAIL"
+	}
+	return ans, nil
+}
+
+// newResolver builds a net.Resolver that talks to the supplied nameservers
+// (host or host:port, port 53 by default), for the HTTP transport's dials.
+// With a SOCKS dialer, queries go over TCP through the proxy.
+func newResolver(nameservers []string, socks *socksDialer) *net.Resolver {
+	var idx uint64
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			i := atomic.AddUint64(&idx, 1) - 1
+			ns := nameservers[i%uint64(len(nameservers))]
+			if _, _, err := net.SplitHostPort(ns); err != nil {
+				ns = net.JoinHostPort(ns, "53")
+			}
+			if socks != nil {
+				return socks.DialContext(ctx, "tcp", ns)
+			}
+			d := net.Dialer{Timeout: 3 * time.Second}
+			return d.DialContext(ctx, "udp", ns)
+		},
+	}
+}
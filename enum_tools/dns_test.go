@@ -0,0 +1,240 @@
+package enum_tools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"slices"
+	"testing"
+)
+
+// dnsHeader builds a message header with the given flags and counts.
+func dnsHeader(id, flags uint16, counts ...uint16) []byte {
+	b := binary.BigEndian.AppendUint16(nil, id)
+	b = binary.BigEndian.AppendUint16(b, flags)
+	for i := 0; i < 4; i++ {
+		var n uint16
+		if i < len(counts) {
+			n = counts[i]
+		}
+		b = binary.BigEndian.AppendUint16(b, n)
+	}
+	return b
+}
+
+func TestReadName(t *testing.T) {
+	// "example.com" at 0, then "www" + a pointer to it at 13.
+	compressed := []byte("\x07example\x03com\x00\x03www\xc0\x00")
+
+	tests := []struct {
+		name string
+		b    []byte
+		off  int
+		want string
+		end  int
+		fail bool
+	}{
+		{"plain", []byte("\x03www\x07example\x03com\x00"), 0, "www.example.com", 17, false},
+		{"lower-cased", []byte("\x03WWW\x07Example\x03COM\x00"), 0, "www.example.com", 17, false},
+		{"root", []byte("\x00"), 0, "", 1, false},
+		{"pointer", compressed, 13, "www.example.com", 19, false},
+		{"pointer only", compressed, 17, "example.com", 19, false},
+		{"pointer to itself", []byte("\xc0\x00"), 0, "", 0, true},
+		{"pointer loop", []byte("\xc0\x02\xc0\x00"), 0, "", 0, true},
+		{"pointer past the end", []byte("\x03www\xc0\x40"), 0, "", 0, true},
+		{"truncated pointer", []byte("\x03www\xc0"), 0, "", 0, true},
+		{"truncated label", []byte("\x05ab"), 0, "", 0, true},
+		{"no terminator", []byte("\x03www"), 0, "", 0, true},
+		{"label too long", append([]byte{64}, bytes.Repeat([]byte("a"), 64)...), 0, "", 0, true},
+		{"offset past the end", []byte("\x00"), 1, "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, end, err := readName(tt.b, tt.off)
+			if tt.fail {
+				if err == nil {
+					t.Fatalf("got %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || end != tt.end {
+				t.Errorf("got %q ending at %d, want %q ending at %d", got, end, tt.want, tt.end)
+			}
+		})
+	}
+}
+
+func TestParseMsg(t *testing.T) {
+	// A reply to "www.example.com" A: a compressed CNAME to
+	// cdn.example.com and its A record.
+	reply := dnsHeader(0x1234, 0x8180, 1, 2, 0, 0)
+	reply = append(reply, "\x03www\x07example\x03com\x00"...) // at 12
+	reply = binary.BigEndian.AppendUint16(reply, dnsTypeA)
+	reply = binary.BigEndian.AppendUint16(reply, dnsClassIN)
+	reply = append(reply, 0xc0, 12) // www.example.com
+	reply = binary.BigEndian.AppendUint16(reply, dnsTypeCNAME)
+	reply = binary.BigEndian.AppendUint16(reply, dnsClassIN)
+	reply = binary.BigEndian.AppendUint32(reply, 300)
+	reply = binary.BigEndian.AppendUint16(reply, 6)
+	cdn := len(reply)
+	reply = append(reply, "\x03cdn\xc0\x10"...) // cdn + example.com
+	reply = append(reply, 0xc0, byte(cdn))
+	reply = binary.BigEndian.AppendUint16(reply, dnsTypeA)
+	reply = binary.BigEndian.AppendUint16(reply, dnsClassIN)
+	reply = binary.BigEndian.AppendUint32(reply, 60)
+	reply = binary.BigEndian.AppendUint16(reply, 4)
+	reply = append(reply, 192, 0, 2, 1)
+
+	m, err := parseMsg(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != 0x1234 || !m.Response || !m.RD || m.Truncated || m.Rcode != rcodeNoError {
+		t.Errorf("header %+v", m)
+	}
+	if m.QName != "www.example.com" || m.QType != dnsTypeA {
+		t.Errorf("question %s type %d", m.QName, m.QType)
+	}
+	if len(m.Answers) != 2 {
+		t.Fatalf("%d answers, want 2", len(m.Answers))
+	}
+	if rr := m.Answers[0]; rr.Name != "www.example.com" || rr.Type != dnsTypeCNAME || rr.TTL != 300 || rr.Target != "cdn.example.com" {
+		t.Errorf("CNAME %+v", rr)
+	}
+	if rr := m.Answers[1]; rr.Name != "cdn.example.com" || rr.Type != dnsTypeA || net.IP(rr.Data).String() != "192.0.2.1" {
+		t.Errorf("A %+v", rr)
+	}
+
+	// Every cut of the message short of its end is malformed.
+	for n := 0; n < len(reply); n++ {
+		if _, err := parseMsg(reply[:n]); err == nil {
+			t.Errorf("truncated to %d bytes: no error", n)
+		}
+	}
+
+	t.Run("flags and OPT", func(t *testing.T) {
+		// TC set, NXDOMAIN, and an OPT record carrying the extended
+		// rcode bits (BADVERS = 16).
+		b := dnsHeader(1, 0x8203, 0, 0, 0, 1)
+		b = append(b, 0)
+		b = binary.BigEndian.AppendUint16(b, dnsTypeOPT)
+		b = binary.BigEndian.AppendUint16(b, 1232)
+		b = binary.BigEndian.AppendUint32(b, 1<<24)
+		b = binary.BigEndian.AppendUint16(b, 0)
+		m, err := parseMsg(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !m.Truncated || !m.EDNS || m.Rcode != 16|rcodeNXDomain {
+			t.Errorf("got truncated %v, EDNS %v, rcode %d", m.Truncated, m.EDNS, m.Rcode)
+		}
+	})
+
+	t.Run("counts past the records", func(t *testing.T) {
+		b := dnsHeader(1, 0x8180, 0, 1)
+		if _, err := parseMsg(b); err == nil {
+			t.Error("no error")
+		}
+	})
+
+	t.Run("CNAME pointer loop", func(t *testing.T) {
+		b := dnsHeader(1, 0x8180, 0, 1)
+		b = append(b, 0)
+		b = binary.BigEndian.AppendUint16(b, dnsTypeCNAME)
+		b = binary.BigEndian.AppendUint16(b, dnsClassIN)
+		b = binary.BigEndian.AppendUint32(b, 60)
+		b = binary.BigEndian.AppendUint16(b, 2)
+		b = append(b, 0xc0, byte(len(b))) // points at itself
+		if _, err := parseMsg(b); err == nil {
+			t.Error("no error")
+		}
+	})
+}
+
+func TestBuildQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		rd      bool
+		udpSize uint16
+	}{
+		{"recursive with EDNS", true, ednsUDPSize},
+		{"iterative with EDNS", false, 4096},
+		{"without EDNS", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := buildQuery(0xbeef, "Www.Example.com.", dnsTypeAAAA, tt.rd, tt.udpSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := parseMsg(q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.ID != 0xbeef || m.Response || m.RD != tt.rd {
+				t.Errorf("header %+v", m)
+			}
+			if m.QName != "www.example.com" || m.QType != dnsTypeAAAA {
+				t.Errorf("question %s type %d", m.QName, m.QType)
+			}
+			if m.EDNS != (tt.udpSize > 0) {
+				t.Errorf("EDNS %v", m.EDNS)
+			}
+			if tt.udpSize == 0 {
+				return
+			}
+			// The OPT record ends the query: root name, type, payload size
+			// as its class, zero TTL and no data.
+			opt := q[len(q)-11:]
+			if opt[0] != 0 || binary.BigEndian.Uint16(opt[1:]) != dnsTypeOPT ||
+				binary.BigEndian.Uint16(opt[3:]) != tt.udpSize ||
+				binary.BigEndian.Uint32(opt[5:]) != 0 || binary.BigEndian.Uint16(opt[9:]) != 0 {
+				t.Errorf("OPT record % x", opt)
+			}
+		})
+	}
+
+	for _, name := range []string{"a..example.com", string(bytes.Repeat([]byte("a"), 64)) + ".com"} {
+		if _, err := buildQuery(1, name, dnsTypeA, true, 0); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestAppendRR(t *testing.T) {
+	rrs := []dnsRR{
+		{Name: "www.example.com", Type: dnsTypeCNAME, TTL: 300, Target: "cdn.example.net"},
+		{Name: "cdn.example.net", Type: dnsTypeA, TTL: 60, Data: []byte{192, 0, 2, 7}},
+		{Name: "example.net", Type: dnsTypeNS, TTL: 3600, Target: "ns1.example.net"},
+	}
+	b := dnsHeader(7, 0x8180, 0, uint16(len(rrs)))
+	for _, rr := range rrs {
+		var err error
+		if b, err = appendRR(b, rr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := parseMsg(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Answers) != len(rrs) {
+		t.Fatalf("%d answers, want %d", len(m.Answers), len(rrs))
+	}
+	for i, want := range rrs {
+		got := m.Answers[i]
+		if got.Name != want.Name || got.Type != want.Type || got.TTL != want.TTL || got.Target != want.Target {
+			t.Errorf("record %d: got %+v, want %+v", i, got, want)
+		}
+		if want.Data != nil && !slices.Equal(got.Data, want.Data) {
+			t.Errorf("record %d: data % x, want % x", i, got.Data, want.Data)
+		}
+	}
+
+	if _, err := appendRR(nil, dnsRR{Name: "example.com", Type: dnsTypeCNAME, Target: "a..b"}); err == nil {
+		t.Error("invalid target: no error")
+	}
+}
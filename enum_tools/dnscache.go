@@ -0,0 +1,151 @@
+package enum_tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// DNS cache
+// ---------------------------------------------------------------------------
+
+const (
+	minCacheTTL        = 30 * time.Second
+	maxCacheTTL        = 24 * time.Hour
+	defaultNegativeTTL = 300 // seconds, for NXDOMAIN without a SOA record
+	dnsCacheVersion    = 2
+)
+
+// DNSCache remembers NOERROR and NXDOMAIN answers for their TTL (clamped
+// to between 30s and a day), so checks and scans resolving the same names
+// don't ask again. It is safe for concurrent use and can be shared by
+// several scanners through Config.DNSCache, and saved to a file to be
+// reused by the next run. Answers are kept per resolver configuration: a
+// scanner only sees those its own resolvers gave.
+type DNSCache struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]dnsCacheEntry
+	dirty   bool
+}
+
+type dnsCacheEntry struct {
+	Answer  *DNSAnswer `json:"answer"`
+	Expires time.Time  `json:"expires"`
+}
+
+type dnsCacheFile struct {
+	Version int                      `json:"version"`
+	Entries map[string]dnsCacheEntry `json:"entries"`
+}
+
+// NewDNSCache returns an empty in-memory cache.
+func NewDNSCache() *DNSCache {
+	return &DNSCache{entries: make(map[string]dnsCacheEntry)}
+}
+
+// LoadDNSCache returns a cache persisted to path, with the entries saved
+// there by a previous run that haven't expired yet. A missing file, or
+// one written by an older version, gives an empty cache.
+func LoadDNSCache(path string) (*DNSCache, error) {
+	c := NewDNSCache()
+	c.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read DNS cache: %w", err)
+	}
+	var f dnsCacheFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("DNS cache %s is corrupt: %w", path, err)
+	}
+	if f.Version < dnsCacheVersion {
+		return c, nil // entries not tied to a resolver; they are dropped
+	}
+	if f.Version != dnsCacheVersion {
+		return nil, fmt.Errorf("DNS cache %s has unsupported version %d", path, f.Version)
+	}
+	now := time.Now()
+	for name, e := range f.Entries {
+		if e.Answer != nil && e.Expires.After(now) {
+			c.entries[name] = e
+		}
+	}
+	return c, nil
+}
+
+// Len returns the number of cached answers.
+func (c *DNSCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Save writes the unexpired entries back to the cache's file, if it has
+// one and anything changed.
+func (c *DNSCache) Save() error {
+	if c.path == "" {
+		return nil
+	}
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	f := dnsCacheFile{Version: dnsCacheVersion, Entries: make(map[string]dnsCacheEntry)}
+	now := time.Now()
+	for name, e := range c.entries {
+		if e.Expires.After(now) {
+			f.Entries[name] = e
+		}
+	}
+	data, err := json.Marshal(&f)
+	c.dirty = false
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path, data)
+}
+
+// cacheKey is the key of name's answer from the resolvers resolver
+// describes. Names are case-insensitive and may be written fully
+// qualified, so both forms share an entry.
+func cacheKey(resolver, name string) string {
+	return resolver + " " + strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// get returns the answer resolver gave for name, if it hasn't expired.
+func (c *DNSCache) get(resolver, name string) (*DNSAnswer, bool) {
+	key := cacheKey(resolver, name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.Expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.Answer, true
+}
+
+// put caches a definitive answer from resolver; failures are not cached.
+func (c *DNSCache) put(resolver string, ans *DNSAnswer) {
+	if ans.Rcode != "NOERROR" && ans.Rcode != "NXDOMAIN" {
+		return
+	}
+	ttl := min(max(time.Duration(ans.TTL)*time.Second, minCacheTTL), maxCacheTTL)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[cacheKey(resolver, ans.Name)] = dnsCacheEntry{Answer: ans, Expires: time.Now().Add(ttl)}
+	c.dirty = true
+}
@@ -0,0 +1,154 @@
+package enum_tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDNSCacheKeys(t *testing.T) {
+	c := NewDNSCache()
+	c.put("ns=1.1.1.1:53", &DNSAnswer{Name: "www.example.com", Rcode: "NOERROR", IPs: []string{"192.0.2.1"}, TTL: 300})
+
+	tests := []struct {
+		resolver, name string
+		hit            bool
+	}{
+		{"ns=1.1.1.1:53", "www.example.com", true},
+		{"ns=1.1.1.1:53", "WWW.Example.COM", true},
+		{"ns=1.1.1.1:53", "www.example.com.", true},
+		{"ns=8.8.8.8:53", "www.example.com", false},
+		{"system", "www.example.com", false},
+		{"ns=1.1.1.1:53", "example.com", false},
+	}
+	for _, tt := range tests {
+		ans, ok := c.get(tt.resolver, tt.name)
+		if ok != tt.hit {
+			t.Errorf("%s from %s: hit %v, want %v", tt.name, tt.resolver, ok, tt.hit)
+		} else if ok && ans.IPs[0] != "192.0.2.1" {
+			t.Errorf("%s from %s: %+v", tt.name, tt.resolver, ans)
+		}
+	}
+
+	// An answer stored under a differently written name replaces it.
+	c.put("ns=1.1.1.1:53", &DNSAnswer{Name: "WWW.EXAMPLE.COM.", Rcode: "NXDOMAIN", TTL: 300})
+	if n := c.Len(); n != 1 {
+		t.Errorf("%d entries, want 1", n)
+	}
+	if ans, _ := c.get("ns=1.1.1.1:53", "www.example.com"); ans == nil || ans.Rcode != "NXDOMAIN" {
+		t.Errorf("got %+v, want the NXDOMAIN answer", ans)
+	}
+}
+
+func TestDNSCacheTTL(t *testing.T) {
+	tests := []struct {
+		rcode string
+		ttl   uint32
+		want  time.Duration // 0: not cached
+	}{
+		{"NOERROR", 300, 300 * time.Second},
+		{"NOERROR", 0, minCacheTTL},
+		{"NOERROR", 5, minCacheTTL},
+		{"NOERROR", 7 * 86400, maxCacheTTL},
+		{"NXDOMAIN", 900, 900 * time.Second},
+		{"SERVFAIL", 300, 0},
+		{"REFUSED", 300, 0},
+		{"TIMEOUT", 300, 0},
+	}
+	for _, tt := range tests {
+		c := NewDNSCache()
+		before := time.Now()
+		c.put("system", &DNSAnswer{Name: "example.com", Rcode: tt.rcode, TTL: tt.ttl})
+		e, ok := c.entries[cacheKey("system", "example.com")]
+		if tt.want == 0 {
+			if ok {
+				t.Errorf("%s: cached", tt.rcode)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%s ttl %d: not cached", tt.rcode, tt.ttl)
+			continue
+		}
+		if got := e.Expires.Sub(before); got < tt.want || got > tt.want+time.Second {
+			t.Errorf("%s ttl %d: expires in %s, want %s", tt.rcode, tt.ttl, got, tt.want)
+		}
+	}
+
+	// An expired entry is a miss and is dropped.
+	c := NewDNSCache()
+	c.put("system", &DNSAnswer{Name: "example.com", Rcode: "NOERROR", TTL: 60})
+	key := cacheKey("system", "example.com")
+	e := c.entries[key]
+	e.Expires = time.Now().Add(-time.Second)
+	c.entries[key] = e
+	if _, ok := c.get("system", "example.com"); ok {
+		t.Error("expired entry: hit")
+	}
+	if n := c.Len(); n != 0 {
+		t.Errorf("%d entries after expiry, want 0", n)
+	}
+}
+
+func TestDNSCacheFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dns.json")
+	c, err := LoadDNSCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 0 {
+		t.Fatalf("missing file: %d entries", c.Len())
+	}
+	c.put("system", &DNSAnswer{Name: "live.example.com", Rcode: "NOERROR", IPs: []string{"192.0.2.1"}, TTL: 300})
+	c.put("system", &DNSAnswer{Name: "stale.example.com", Rcode: "NXDOMAIN", TTL: 300})
+	key := cacheKey("system", "stale.example.com")
+	e := c.entries[key]
+	e.Expires = time.Now().Add(-time.Second)
+	c.entries[key] = e
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = LoadDNSCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := c.Len(); n != 1 {
+		t.Errorf("%d entries, want only the unexpired one", n)
+	}
+	if ans, ok := c.get("system", "live.example.com"); !ok || ans.IPs[0] != "192.0.2.1" {
+		t.Errorf("live.example.com: %+v, %v", ans, ok)
+	}
+
+	tests := []struct {
+		name, data string
+		entries    int
+		fail       bool
+	}{
+		{"version 1 dropped", `{"version":1,"entries":{"www.example.com":{"answer":{"name":"www.example.com","rcode":"NOERROR"},"expires":"2999-01-01T00:00:00Z"}}}`, 0, false},
+		{"newer version", `{"version":99,"entries":{}}`, 0, true},
+		{"corrupt", `{"version":`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "dns.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			c, err := LoadDNSCache(path)
+			if tt.fail {
+				if err == nil {
+					t.Fatal("no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Len() != tt.entries {
+				t.Errorf("%d entries, want %d", c.Len(), tt.entries)
+			}
+		})
+	}
+}
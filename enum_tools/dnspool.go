@@ -0,0 +1,283 @@
+package enum_tools
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Resolver pool health
+// ---------------------------------------------------------------------------
+
+// Nameservers are checked before the first lookup, then weighted by their
+// latency and error rate during the scan. Dead, refusing or lying ones are
+// evicted, as long as others are left.
+
+const (
+	// healthCheckName must resolve through any working recursive resolver
+	// (and through the simulator).
+	healthCheckName = "storage.googleapis.com"
+
+	// Eviction needs this many queries and an error rate above maxErrorRate.
+	minQueriesForEviction = 20
+	maxErrorRate          = 0.5
+
+	ewmaAlpha = 0.1
+)
+
+// errNoNameservers is returned once every nameserver has been evicted.
+var errNoNameservers = fmt.Errorf("%w: no usable nameserver left", ErrNameserver)
+
+// ResolverStats describes one nameserver's share of a scan.
+type ResolverStats struct {
+	Server  string
+	Queries int64
+	Errors  int64         // timeouts, connection errors and refusals
+	Latency time.Duration // average over answered queries
+	Evicted string        // why it was dropped, "" while in use
+}
+
+// nsHealth tracks one nameserver during the scan.
+type nsHealth struct {
+	mu       sync.Mutex
+	queries  int64
+	errors   int64
+	answered int64
+	total    time.Duration // latency of the answered queries
+	latency  float64       // EWMA, seconds
+	errRate  float64       // EWMA of failures
+	evicted  string
+}
+
+// score is lower for faster, more reliable servers.
+func (h *nsHealth) score() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return (h.latency + 0.001) * (1 + 10*h.errRate)
+}
+
+// active returns the nameservers not evicted.
+func (c *dnsClient) active() []*nameserver {
+	var out []*nameserver
+	for _, ns := range c.servers {
+		ns.health.mu.Lock()
+		if ns.health.evicted == "" {
+			out = append(out, ns)
+		}
+		ns.health.mu.Unlock()
+	}
+	return out
+}
+
+// pick chooses the better of two random active nameservers, preferring
+// ones not tried yet for this query. Returns nil when none are left.
+func (c *dnsClient) pick(tried []*nameserver) *nameserver {
+	active := c.active()
+	var fresh []*nameserver
+	for _, ns := range active {
+		if !containsNS(tried, ns) {
+			fresh = append(fresh, ns)
+		}
+	}
+	if len(fresh) > 0 {
+		active = fresh
+	}
+	switch len(active) {
+	case 0:
+		return nil
+	case 1:
+		return active[0]
+	}
+	a, b := active[rand.Intn(len(active))], active[rand.Intn(len(active))]
+	if b.health.score() < a.health.score() {
+		return b
+	}
+	return a
+}
+
+func containsNS(list []*nameserver, ns *nameserver) bool {
+	for _, n := range list {
+		if n == ns {
+			return true
+		}
+	}
+	return false
+}
+
+// record updates a nameserver's health after a query and evicts it when
+// it refuses us or fails too often, unless it is the last one left.
+func (c *dnsClient) record(ctx context.Context, ns *nameserver, took time.Duration, msg *dnsMsg, err error) {
+	if ctx.Err() != nil {
+		return // cancelled, not the server's fault
+	}
+	refused := msg != nil && (msg.Rcode == rcodeRefused || msg.Rcode == rcodeNotImp)
+	failed := err != nil || refused
+
+	h := &ns.health
+	h.mu.Lock()
+	h.queries++
+	fail := 0.0
+	if failed {
+		h.errors++
+		fail = 1
+	} else {
+		h.answered++
+		h.total += took
+		if h.answered == 1 {
+			h.latency = took.Seconds()
+		} else {
+			h.latency += ewmaAlpha * (took.Seconds() - h.latency)
+		}
+	}
+	h.errRate += ewmaAlpha * (fail - h.errRate)
+	var reason string
+	switch {
+	case refused:
+		reason = "answered " + RcodeName(msg.Rcode)
+	case h.queries >= minQueriesForEviction && h.errRate > maxErrorRate:
+		reason = fmt.Sprintf("%.0f%% of recent queries failed", h.errRate*100)
+	}
+	h.mu.Unlock()
+
+	if reason != "" {
+		c.evict(ns, reason)
+	}
+}
+
+// evict drops ns from the rotation, unless it is the last one left.
+func (c *dnsClient) evict(ns *nameserver, reason string) bool {
+	c.evictMu.Lock()
+	defer c.evictMu.Unlock()
+	if len(c.active()) <= 1 {
+		return false
+	}
+	ns.health.mu.Lock()
+	already := ns.health.evicted != ""
+	if !already {
+		ns.health.evicted = reason
+	}
+	ns.health.mu.Unlock()
+	if !already && c.warn != nil {
+		c.warn("    [!] Dropping nameserver %s: %s\n", ns, reason)
+	}
+	return !already
+}
+
+// validate checks every nameserver against a name that must resolve and
+// a random one under .invalid that must not, and evicts those that fail.
+// Liars are only evicted when honest servers are left. Returns
+// errNoNameservers when none answered.
+func (c *dnsClient) validate(ctx context.Context) error {
+	type verdict struct {
+		dead, lying string
+	}
+	verdicts := make([]verdict, len(c.servers))
+	var wg sync.WaitGroup
+	for i, ns := range c.servers {
+		wg.Add(1)
+		go func(i int, ns *nameserver) {
+			defer wg.Done()
+			msg, err := c.exchangeWith(ctx, ns, healthCheckName, dnsTypeA)
+			switch {
+			case err != nil:
+				verdicts[i].dead = err.Error()
+				return
+			case msg.Rcode != rcodeNoError || len(msg.Answers) == 0:
+				verdicts[i].dead = fmt.Sprintf("answered %s for %s", RcodeName(msg.Rcode), healthCheckName)
+				return
+			}
+			bogus := randomLabel() + ".invalid"
+			if msg, err := c.exchangeWith(ctx, ns, bogus, dnsTypeA); err == nil && msg.Rcode == rcodeNoError && len(msg.Answers) > 0 {
+				verdicts[i].lying = "answers for non-existent names"
+			}
+		}(i, ns)
+	}
+	wg.Wait()
+
+	var alive, honest int
+	for _, v := range verdicts {
+		if v.dead == "" {
+			alive++
+			if v.lying == "" {
+				honest++
+			}
+		}
+	}
+	if alive == 0 {
+		return fmt.Errorf("%w: no nameserver answered (%s: %s)", errNoNameservers, c.servers[0], verdicts[0].dead)
+	}
+	for i, ns := range c.servers {
+		switch v := verdicts[i]; {
+		case v.dead != "":
+			c.evict(ns, v.dead)
+		case v.lying != "" && honest > 0:
+			c.evict(ns, v.lying)
+		case v.lying != "" && c.warn != nil:
+			c.warn("    [!] Nameserver %s %s; wildcard detection will have to cope.\n", ns, v.lying)
+		}
+	}
+	return nil
+}
+
+// stats returns the per-nameserver statistics.
+func (c *dnsClient) stats() []ResolverStats {
+	var out []ResolverStats
+	for _, ns := range c.servers {
+		h := &ns.health
+		h.mu.Lock()
+		st := ResolverStats{Server: ns.String(), Queries: h.queries, Errors: h.errors, Evicted: h.evicted}
+		if h.answered > 0 {
+			st.Latency = h.total / time.Duration(h.answered)
+		}
+		h.mu.Unlock()
+		out = append(out, st)
+	}
+	return out
+}
+
+// recursiveClient returns the client for the recursive nameservers, or
+// nil for the platform resolver.
+func (s *Scanner) recursiveClient() *dnsClient {
+	switch r := s.dns.(type) {
+	case *dnsClient:
+		return r
+	case *authResolver:
+		return r.recursive
+	}
+	return nil
+}
+
+// checkResolvers validates the nameservers once, before the first lookup.
+func (s *Scanner) checkResolvers(ctx context.Context) error {
+	c := s.recursiveClient()
+	if c == nil || s.cfg.DNSOverride != "" {
+		return nil // a deliberate stand-in is taken as is
+	}
+	s.dnsCheck.Do(func() {
+		s.Printf("[*] Checking %d nameserver(s)\n", len(c.servers))
+		s.dnsCheckErr = c.validate(ctx)
+	})
+	return s.dnsCheckErr
+}
+
+// ResolverStats returns how each nameserver fared, authoritative ones
+// included, or nil for the platform resolver.
+func (s *Scanner) ResolverStats() []ResolverStats {
+	c := s.recursiveClient()
+	if c == nil {
+		return nil
+	}
+	stats := c.stats()
+	if r, ok := s.dns.(*authResolver); ok {
+		for _, ac := range r.clients() {
+			for _, st := range ac.stats() {
+				st.Server += " (" + ac.zone + ")"
+				stats = append(stats, st)
+			}
+		}
+	}
+	return stats
+}
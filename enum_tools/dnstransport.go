@@ -0,0 +1,209 @@
+package enum_tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Nameservers
+// ---------------------------------------------------------------------------
+
+// nameserver is one resolver of a dnsClient's rotation: classic DNS on
+// port 53, DNS over TLS (RFC 7858) or DNS over HTTPS (RFC 8484).
+type nameserver struct {
+	spec  string // as shown in messages
+	proto string // "udp", "tls" or "https"
+	addr  string // host:port, for udp and tls
+
+	tlsConfig *tls.Config // DoT
+	idle      *connPool   // DoT connections kept for reuse
+	doh       *http.Client
+
+	limit  *tokenBucket // queries per second, for authoritative servers
+	health nsHealth
+}
+
+func (ns *nameserver) String() string { return ns.spec }
+
+// parseNameserver parses a -ns / -nsf entry: host[:port], tls://host[:port]
+// or an https:// (or, for local stand-ins, http://) DoH URL, whose path
+// defaults to /dns-query. With a SOCKS dialer, DoT and DoH go through the
+// proxy too.
+func parseNameserver(spec string, socks *socksDialer, insecure bool) (*nameserver, error) {
+	switch {
+	case strings.HasPrefix(spec, "https://"), strings.HasPrefix(spec, "http://"):
+		u, err := url.Parse(spec)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid DNS-over-HTTPS resolver %q", spec)
+		}
+		if u.Path == "" {
+			u.Path = "/dns-query"
+		}
+		transport := &http.Transport{
+			TLSClientConfig:     &tls.Config{InsecureSkipVerify: insecure},
+			ForceAttemptHTTP2:   true,
+			MaxIdleConnsPerHost: 64,
+			IdleConnTimeout:     90 * time.Second,
+		}
+		if socks != nil {
+			transport.Proxy = http.ProxyURL(socks.proxy)
+		}
+		return &nameserver{spec: u.String(), proto: "https", doh: &http.Client{Transport: transport}}, nil
+
+	case strings.HasPrefix(spec, "tls://"):
+		hostport := strings.TrimSuffix(strings.TrimPrefix(spec, "tls://"), "/")
+		host, port, err := net.SplitHostPort(hostport)
+		if err != nil {
+			host, port = strings.Trim(hostport, "[]"), "853"
+		}
+		if host == "" {
+			return nil, fmt.Errorf("invalid DNS-over-TLS resolver %q", spec)
+		}
+		addr := net.JoinHostPort(host, port)
+		return &nameserver{
+			spec:      "tls://" + addr,
+			proto:     "tls",
+			addr:      addr,
+			tlsConfig: &tls.Config{ServerName: host, InsecureSkipVerify: insecure},
+			idle:      &connPool{},
+		}, nil
+
+	case strings.Contains(spec, "://"):
+		return nil, fmt.Errorf("nameserver %q: scheme must be tls:// or https://", spec)
+	}
+	addr := spec
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), "53")
+	}
+	return &nameserver{spec: addr, proto: "udp", addr: addr}, nil
+}
+
+// ---------------------------------------------------------------------------
+// DNS over TLS
+// ---------------------------------------------------------------------------
+
+// maxIdleDoT bounds the idle connections kept per DoT server.
+const maxIdleDoT = 64
+
+// connPool holds idle connections to one server.
+type connPool struct {
+	mu   sync.Mutex
+	idle []net.Conn
+}
+
+func (p *connPool) get() net.Conn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.idle) == 0 {
+		return nil
+	}
+	conn := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+	return conn
+}
+
+func (p *connPool) put(conn net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.idle) >= maxIdleDoT {
+		conn.Close()
+		return
+	}
+	p.idle = append(p.idle, conn)
+}
+
+// exchangeDoT sends a query over a pooled TLS connection. A reused
+// connection the server has closed in the meantime is replaced once.
+func (c *dnsClient) exchangeDoT(ctx context.Context, ns *nameserver, query []byte) (*dnsMsg, error) {
+	for {
+		conn := ns.idle.get()
+		reused := conn != nil
+		if !reused {
+			var err error
+			if conn, err = c.dialDoT(ctx, ns); err != nil {
+				return nil, err
+			}
+		}
+		conn.SetDeadline(c.deadline(ctx))
+		msg, err := tcpExchange(conn, query)
+		if err != nil {
+			conn.Close()
+			if reused && ctx.Err() == nil {
+				continue
+			}
+			return nil, err
+		}
+		conn.SetDeadline(time.Time{})
+		ns.idle.put(conn)
+		return msg, nil
+	}
+}
+
+func (c *dnsClient) dialDoT(ctx context.Context, ns *nameserver) (net.Conn, error) {
+	ctx, cancel := context.WithDeadline(ctx, c.deadline(ctx))
+	defer cancel()
+	var raw net.Conn
+	var err error
+	if c.socks != nil {
+		raw, err = c.socks.DialContext(ctx, "tcp", ns.addr)
+	} else {
+		var d net.Dialer
+		raw, err = d.DialContext(ctx, "tcp", ns.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn := tls.Client(raw, ns.tlsConfig)
+	if err := conn.HandshakeContext(ctx); err != nil {
+		raw.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// ---------------------------------------------------------------------------
+// DNS over HTTPS
+// ---------------------------------------------------------------------------
+
+// exchangeDoH POSTs a query to a DoH endpoint. Its HTTP client keeps the
+// connections alive (over HTTP/2 where the server supports it).
+func (c *dnsClient) exchangeDoH(ctx context.Context, ns *nameserver, query []byte) (*dnsMsg, error) {
+	ctx, cancel := context.WithDeadline(ctx, c.deadline(ctx))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ns.spec, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := ns.doh.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH resolver %s: %s", ns.spec, resp.Status)
+	}
+	msg, err := parseMsg(body)
+	if err != nil {
+		return nil, err
+	}
+	if !isReplyTo(msg, query) {
+		return nil, fmt.Errorf("DoH resolver %s: reply doesn't match the query", ns.spec)
+	}
+	return msg, nil
+}
@@ -0,0 +1,178 @@
+package enum_tools
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// transportScenario is the zone the DoH and DoT stand-ins answer for.
+var transportScenario = &Scenario{
+	Address: "127.0.0.1",
+	DNS:     []string{"cdn.example.net"},
+	CNAMEs:  map[string]string{"www.example.com": "cdn.example.net"},
+}
+
+// checkResolved resolves www.example.com and missing.example.com through
+// c and checks the answers.
+func checkResolved(t *testing.T, c *dnsClient) {
+	t.Helper()
+	ctx := context.Background()
+	ans, err := c.resolve(ctx, "www.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ans.Rcode != "NOERROR" || !slices.Equal(ans.CNAMEs, []string{"cdn.example.net"}) || !slices.Equal(ans.IPs, []string{"127.0.0.1"}) {
+		t.Errorf("www.example.com: %+v", ans)
+	}
+	ans, err = c.resolve(ctx, "missing.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ans.Rcode != "NXDOMAIN" {
+		t.Errorf("missing.example.com: rcode %s", ans.Rcode)
+	}
+}
+
+func TestParseNameserver(t *testing.T) {
+	tests := []struct {
+		spec, proto, shown string
+		fail               bool
+	}{
+		{"1.1.1.1", "udp", "1.1.1.1:53", false},
+		{"127.0.0.1:5353", "udp", "127.0.0.1:5353", false},
+		{"[2606:4700::1111]", "udp", "[2606:4700::1111]:53", false},
+		{"tls://dns.example", "tls", "tls://dns.example:853", false},
+		{"tls://127.0.0.1:8853/", "tls", "tls://127.0.0.1:8853", false},
+		{"https://dns.example", "https", "https://dns.example/dns-query", false},
+		{"http://127.0.0.1:8080/resolve", "https", "http://127.0.0.1:8080/resolve", false},
+		{"https://", "", "", true},
+		{"tls://", "", "", true},
+		{"quic://dns.example", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			ns, err := parseNameserver(tt.spec, nil, false)
+			if tt.fail {
+				if err == nil {
+					t.Fatalf("got %s, want an error", ns)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ns.proto != tt.proto || ns.String() != tt.shown {
+				t.Errorf("got %s %s, want %s %s", ns.proto, ns, tt.proto, tt.shown)
+			}
+		})
+	}
+}
+
+func TestDoHSimulator(t *testing.T) {
+	sim := NewSimulator(transportScenario)
+	if err := sim.Start("127.0.0.1:0", "127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Close()
+
+	c, err := newDNSClient([]string{"http://" + sim.HTTPAddr()}, nil, false, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResolved(t, c)
+}
+
+func TestDoHExchange(t *testing.T) {
+	sim := NewSimulator(transportScenario)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/dns-query" ||
+			r.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		query, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(sim.answer(query))
+	}))
+	defer srv.Close()
+
+	c, err := newDNSClient([]string{srv.URL}, nil, true, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResolved(t, c)
+
+	// A resolver that doesn't answer with a DNS message is an error.
+	bad, err := newDNSClient([]string{srv.URL + "/elsewhere"}, nil, true, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bad.exchangeWith(context.Background(), bad.servers[0], "www.example.com", dnsTypeA); err == nil {
+		t.Error("HTTP 400: no error")
+	}
+}
+
+func TestDoTExchange(t *testing.T) {
+	sim := NewSimulator(transportScenario)
+	// The httptest server only lends its certificate.
+	cert := httptest.NewUnstartedServer(nil)
+	cert.StartTLS()
+	cert.Close()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: cert.TLS.Certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	var conns atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns.Add(1)
+			go func() {
+				defer conn.Close()
+				for {
+					var n uint16
+					if err := binary.Read(conn, binary.BigEndian, &n); err != nil {
+						return
+					}
+					query := make([]byte, n)
+					if _, err := io.ReadFull(conn, query); err != nil {
+						return
+					}
+					reply := sim.answer(query)
+					conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(reply))), reply...))
+				}
+			}()
+		}
+	}()
+
+	c, err := newDNSClient([]string{"tls://" + ln.Addr().String()}, nil, true, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResolved(t, c)
+	if n := conns.Load(); n != 1 {
+		t.Errorf("%d connections, want 1 reused for every query", n)
+	}
+
+	// Without -insecure the stand-in's certificate isn't trusted.
+	strict, err := newDNSClient([]string{"tls://" + ln.Addr().String()}, nil, false, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := strict.exchangeWith(context.Background(), strict.servers[0], "www.example.com", dnsTypeA); err == nil {
+		t.Error("untrusted certificate: no error")
+	}
+}
@@ -0,0 +1,142 @@
+package enum_tools
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Endpoints
+// ---------------------------------------------------------------------------
+
+// Endpoint describes where a service's candidates are sent. Overriding it
+// lets a check target a local stand-in (MinIO, Azurite, fake GCS, ...).
+type Endpoint struct {
+	Scheme    string // "http" or "https"; empty keeps the check's default
+	Host      string // host template with {name} / {region} placeholders
+	Port      int    // 0 keeps the scheme's default port
+	PathStyle bool   // put the name in the path (host/{name}) instead of the host
+}
+
+// DefaultEndpoints maps each service to its real cloud endpoint.
+var DefaultEndpoints = map[string]Endpoint{
+	"s3":        {Host: "{name}." + s3URL},
+	"awsapps":   {Host: "{name}." + appsURL},
+	"blob":      {Host: "{name}." + blobURL},
+	"file":      {Host: "{name}." + fileURL},
+	"queue":     {Host: "{name}." + queueURL},
+	"table":     {Host: "{name}." + tableURL},
+	"scm":       {Host: "{name}." + mgmtURL},
+	"vault":     {Host: "{name}." + vaultURL},
+	"websites":  {Host: "{name}." + webappURL},
+	"databases": {Host: "{name}." + databaseURL},
+	"vms":       {Host: "{name}.{region}." + vmURL},
+	"gcs":       {Host: gcpURL, PathStyle: true},
+	"rtdb":      {Host: "{name}." + fbrtdbURL},
+	"fbapp":     {Host: "{name}." + fbappURL},
+	"appspot":   {Host: "{name}." + appspotURL},
+	"functions": {Host: "{region}-{name}." + funcURL},
+}
+
+// dnsServices are resolved before (or instead of) being probed over HTTP,
+// so their host template must contain the name.
+var dnsServices = map[string]bool{
+	"awsapps": true, "blob": true, "file": true, "queue": true, "table": true,
+	"scm": true, "vault": true, "websites": true, "databases": true, "vms": true,
+}
+
+// Hostname expands the host template for name and region.
+func (e Endpoint) Hostname(name, region string) string {
+	return strings.NewReplacer("{name}", name, "{region}", region).Replace(e.Host)
+}
+
+// URL returns the URL of name's resource with path appended, using
+// defaultScheme unless the endpoint overrides it.
+func (e Endpoint) URL(name, region, path, defaultScheme string) string {
+	if e.PathStyle {
+		path = "/" + name + path
+	}
+	return e.URLForHost(e.Hostname(name, region), path, defaultScheme)
+}
+
+// URLForHost returns the URL for an already expanded (e.g. resolved)
+// hostname with path appended.
+func (e Endpoint) URLForHost(host, path, defaultScheme string) string {
+	scheme := e.Scheme
+	if scheme == "" {
+		scheme = defaultScheme
+	}
+	if e.Port != 0 {
+		host += ":" + strconv.Itoa(e.Port)
+	}
+	return scheme + "://" + host + path
+}
+
+// endpoint returns the configured endpoint for a service, falling back to
+// the real cloud endpoint.
+func (cfg *Config) endpoint(service string) Endpoint {
+	if e, ok := cfg.Endpoints[service]; ok {
+		return e
+	}
+	return DefaultEndpoints[service]
+}
+
+// ParseEndpoint parses a "service=URL" override such as
+// "s3=http://{name}.s3.lab:9000" or "gcs=http://127.0.0.1:4443/{name}".
+// A {name} in the path selects path-style addressing.
+func ParseEndpoint(spec string) (string, Endpoint, error) {
+	service, raw, ok := strings.Cut(spec, "=")
+	if !ok {
+		return "", Endpoint{}, fmt.Errorf("endpoint %q must be service=URL", spec)
+	}
+	if _, known := DefaultEndpoints[service]; !known {
+		return "", Endpoint{}, fmt.Errorf("unknown endpoint service %q (known: %s)", service, strings.Join(EndpointServices(), ", "))
+	}
+
+	// Placeholders aren't valid URL syntax; swap them out while parsing.
+	raw = strings.NewReplacer("{name}", "name-placeholder", "{region}", "region-placeholder").Replace(raw)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", Endpoint{}, fmt.Errorf("endpoint %q: %w", spec, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", Endpoint{}, fmt.Errorf("endpoint %q: scheme must be http or https", spec)
+	}
+	restore := strings.NewReplacer("name-placeholder", "{name}", "region-placeholder", "{region}")
+
+	e := Endpoint{Scheme: u.Scheme, Host: restore.Replace(u.Hostname())}
+	if p := u.Port(); p != "" {
+		if e.Port, err = strconv.Atoi(p); err != nil {
+			return "", Endpoint{}, fmt.Errorf("endpoint %q: bad port", spec)
+		}
+	}
+	path := strings.Trim(restore.Replace(u.Path), "/")
+	switch path {
+	case "":
+	case "{name}":
+		e.PathStyle = true
+	default:
+		return "", Endpoint{}, fmt.Errorf("endpoint %q: path may only be /{name}", spec)
+	}
+
+	if e.PathStyle == strings.Contains(e.Host, "{name}") {
+		return "", Endpoint{}, fmt.Errorf("endpoint %q: {name} must appear in either the host or the path", spec)
+	}
+	if dnsServices[service] && e.PathStyle {
+		return "", Endpoint{}, fmt.Errorf("endpoint %q: %s is resolved over DNS and can't be path-style", spec, service)
+	}
+	return service, e, nil
+}
+
+// EndpointServices lists the services whose endpoint can be overridden.
+func EndpointServices() []string {
+	var out []string
+	for service := range DefaultEndpoints {
+		out = append(out, service)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -1,9 +1,8 @@
 package enum_tools
 
 import (
-	"fmt"
+	"context"
 	"strings"
-	"sync"
 )
 
 const gcpBanner = `
@@ -20,310 +19,132 @@ const (
 	fbappURL   = "firebaseapp.com"
 )
 
-// hasFuncs collects project/region URLs that have at least one Cloud Function.
-var (
-	hasFuncs   []string
-	hasFuncsMu sync.Mutex
-)
-
-// ---------------------------------------------------------------------------
-// GCP Bucket checks
-// ---------------------------------------------------------------------------
-
-func printBucketResponse(result *HttpResult) bool {
-	data := OutputData{Platform: "gcp"}
-
-	switch {
-	case result.StatusCode == 404:
-		// Not found.
-	case result.StatusCode == 200:
-		data.Msg = "OPEN GOOGLE BUCKET"
-		data.Target = result.URL
-		data.Access = "public"
-		FmtOutput(data)
-		ListBucketContents(result.URL + "/")
-	case result.StatusCode == 403:
-		data.Msg = "Protected Google Bucket"
-		data.Target = result.URL
-		data.Access = "protected"
-		FmtOutput(data)
-	default:
-		fmt.Printf("    Unknown status codes being received from %s:\n       %d: %s\n",
-			result.URL, result.StatusCode, result.Reason)
-	}
-	return false
-}
-
-func checkGCPBuckets(names []string, threads int) {
-	fmt.Println("[+] Checking for Google buckets")
-	start := StartTimer()
-
-	var candidates []string
-	for _, n := range names {
-		candidates = append(candidates, gcpURL+"/"+n)
-	}
-
-	GetURLBatch(candidates, false, printBucketResponse, threads, true)
-	StopTimer(start)
-}
-
-// ---------------------------------------------------------------------------
-// Firebase Realtime Database
-// ---------------------------------------------------------------------------
-
-func printFBRTDBResponse(result *HttpResult) bool {
-	data := OutputData{Platform: "gcp"}
-
-	switch {
-	case result.StatusCode == 404:
-		// Not found.
-	case result.StatusCode == 200:
-		data.Msg = "OPEN GOOGLE FIREBASE RTDB"
-		data.Target = result.URL
-		data.Access = "public"
-		FmtOutput(data)
-	case result.StatusCode == 401:
-		data.Msg = "Protected Google Firebase RTDB"
-		data.Target = result.URL
-		data.Access = "protected"
-		FmtOutput(data)
-	case result.StatusCode == 402:
-		data.Msg = "Payment required on Google Firebase RTDB"
-		data.Target = result.URL
-		data.Access = "disabled"
-		FmtOutput(data)
-	case result.StatusCode == 423:
-		data.Msg = "The Firebase database has been deactivated."
-		data.Target = result.URL
-		data.Access = "disabled"
-		FmtOutput(data)
-	default:
-		fmt.Printf("    Unknown status codes being received from %s:\n       %d: %s\n",
-			result.URL, result.StatusCode, result.Reason)
-	}
-	return false
-}
-
-func checkFBRTDB(names []string, threads int) {
-	fmt.Println("[+] Checking for Google Firebase Realtime Databases")
-	start := StartTimer()
-
-	var candidates []string
-	for _, n := range names {
-		if !strings.Contains(n, ".") {
-			candidates = append(candidates, n+"."+fbrtdbURL+"/.json")
-		}
-	}
-
-	GetURLBatch(candidates, true, printFBRTDBResponse, threads, false)
-	StopTimer(start)
-}
-
-// ---------------------------------------------------------------------------
-// Firebase App
-// ---------------------------------------------------------------------------
-
-func printFBAppResponse(result *HttpResult) bool {
-	data := OutputData{Platform: "gcp"}
-
-	switch {
-	case result.StatusCode == 404:
-		// Not found.
-	case result.StatusCode == 200:
-		data.Msg = "OPEN GOOGLE FIREBASE APP"
-		data.Target = result.URL
-		data.Access = "public"
-		FmtOutput(data)
-	default:
-		fmt.Printf("    Unknown status codes being received from %s:\n       %d: %s\n",
-			result.URL, result.StatusCode, result.Reason)
-	}
-	return false
-}
-
-// CheckFBApp checks for Google Firebase Applications.
-// NOTE: This function exists but is NOT called by RunAllGCP, matching the
-// original Python project behaviour.
-func CheckFBApp(names []string, threads int) {
-	fmt.Println("[+] Checking for Google Firebase Applications")
-	start := StartTimer()
-
-	var candidates []string
-	for _, n := range names {
-		if !strings.Contains(n, ".") {
-			candidates = append(candidates, n+"."+fbappURL)
-		}
-	}
-
-	GetURLBatch(candidates, true, printFBAppResponse, threads, false)
-	StopTimer(start)
-}
-
 // ---------------------------------------------------------------------------
-// App Engine (appspot.com)
+// Project-named services (Firebase, App Engine)
 // ---------------------------------------------------------------------------
 
-func printAppspotResponse(result *HttpResult) bool {
-	data := OutputData{Platform: "gcp"}
-
-	switch {
-	case result.StatusCode == 404:
-		// Not found.
-	case result.StatusCode/100 == 5:
-		data.Msg = "Google App Engine app with a 50x error"
-		data.Target = result.URL
-		data.Access = "public"
-		FmtOutput(data)
-	case result.StatusCode == 200 || result.StatusCode == 302:
-		if strings.Contains(result.URL, "accounts.google.com") {
-			data.Msg = "Protected Google App Engine app"
-			data.Target = result.OriginalURL
-			data.Access = "protected"
-		} else {
-			data.Msg = "Open Google App Engine app"
-			data.Target = result.URL
-			data.Access = "public"
-		}
-		FmtOutput(data)
-	default:
-		fmt.Printf("    Unknown status codes being received from %s:\n       %d: %s\n",
-			result.URL, result.StatusCode, result.Reason)
-	}
-	return false
-}
-
-func checkAppspot(names []string, threads int) {
-	fmt.Println("[+] Checking for Google App Engine apps")
-	start := StartTimer()
-
-	var candidates []string
-	for _, n := range names {
-		if !strings.Contains(n, ".") {
-			candidates = append(candidates, n+"."+appspotURL)
+// undottedCandidates returns a builder producing the service URL of every
+// name without a dot (these services don't allow dotted project names).
+func undottedCandidates(service, defaultScheme, path string) func([]string, *Config, map[string][]string) []string {
+	build := urlCandidates(service, defaultScheme, path)
+	return func(names []string, cfg *Config, deps map[string][]string) []string {
+		var undotted []string
+		for _, n := range names {
+			if !strings.Contains(n, ".") {
+				undotted = append(undotted, n)
+			}
 		}
+		return build(undotted, cfg, deps)
 	}
-
-	GetURLBatch(candidates, false, printAppspotResponse, threads, true)
-	StopTimer(start)
 }
 
 // ---------------------------------------------------------------------------
 // Cloud Functions (cloudfunctions.net)
 // ---------------------------------------------------------------------------
 
-func printFunctionsResponse1(result *HttpResult) bool {
-	data := OutputData{Platform: "gcp"}
-
-	switch {
-	case result.StatusCode == 404:
-		// Not found.
-	case result.StatusCode == 302:
-		data.Msg = "Contains at least 1 Cloud Function"
-		data.Target = result.URL
-		data.Access = "public"
-		FmtOutput(data)
-		hasFuncsMu.Lock()
-		hasFuncs = append(hasFuncs, result.URL)
-		hasFuncsMu.Unlock()
-	default:
-		fmt.Printf("    Unknown status codes being received from %s:\n       %d: %s\n",
-			result.URL, result.StatusCode, result.Reason)
-	}
-	return false
-}
-
-func printFunctionsResponse2(result *HttpResult) bool {
-	data := OutputData{Platform: "gcp"}
-
-	switch {
-	case strings.Contains(result.URL, "accounts.google.com/ServiceLogin"):
-		// Redirected to Google login — skip.
-	case result.StatusCode == 403 || result.StatusCode == 401:
-		data.Msg = "Auth required Cloud Function"
-		data.Target = result.URL
-		data.Access = "protected"
-		FmtOutput(data)
-	case result.StatusCode == 405:
-		data.Msg = "UNAUTHENTICATED Cloud Function (POST-Only)"
-		data.Target = result.URL
-		data.Access = "public"
-		FmtOutput(data)
-	case result.StatusCode == 200 || result.StatusCode == 404:
-		data.Msg = "UNAUTHENTICATED Cloud Function (GET-OK)"
-		data.Target = result.URL
-		data.Access = "public"
-		FmtOutput(data)
-	default:
-		fmt.Printf("    Unknown status codes being received from %s:\n       %d: %s\n",
-			result.URL, result.StatusCode, result.Reason)
-	}
-	return false
-}
-
-func checkFunctions(names []string, bruteData string, quickscan bool, threads int) {
-	fmt.Println("[+] Checking for project/zones with Google Cloud Functions.")
-	start := StartTimer()
-
-	regions := GCPRegions
-	fmt.Printf("[*] Testing across %d regions defined in the config file\n", len(regions))
-
+func functionCandidates(names []string, cfg *Config, _ map[string][]string) []string {
+	ep := cfg.endpoint("functions")
 	var candidates []string
-	for _, region := range regions {
+	for _, region := range GCPRegions {
 		for _, n := range names {
-			candidates = append(candidates, region+"-"+n+"."+funcURL)
+			candidates = append(candidates, ep.URL(n, region, "", "http"))
 		}
 	}
+	return candidates
+}
 
-	// Reset global list.
-	hasFuncsMu.Lock()
-	hasFuncs = nil
-	hasFuncsMu.Unlock()
+// runFunctions finds project/region combos hosting at least one Cloud
+// Function, then brute-forces function names inside each of them.
+func runFunctions(ctx context.Context, c *check, s *Scanner, candidates []string) ([]string, error) {
+	s.Printf("[*] Testing across %d regions defined in the config file\n", len(GCPRegions))
 
-	GetURLBatch(candidates, false, printFunctionsResponse1, threads, false)
+	if err := s.GetURLBatch(ctx, candidates, false, c.classifier(s), false); err != nil {
+		return nil, err
+	}
 
-	hasFuncsMu.Lock()
-	found := make([]string, len(hasFuncs))
-	copy(found, hasFuncs)
-	hasFuncsMu.Unlock()
+	// Kept in the checkpoint so a resumed scan still knows about projects
+	// found before it was interrupted.
+	found := s.cp.recall("gcp-functions")
 
-	if len(found) == 0 {
-		StopTimer(start)
-		return
-	}
-	if quickscan {
-		return
+	if len(found) == 0 || s.cfg.QuickScan {
+		return found, nil
 	}
 
-	fmt.Printf("[*] Brute-forcing function names in %d project/region combos\n", len(found))
+	s.Printf("[*] Brute-forcing function names in %d project/region combos\n", len(found))
 
-	bruteStrings := GetBrute(bruteData, 1, 63)
+	bruteStrings := GetBrute(s.cfg.BruteData, 1, 63)
+	classifyName := ruleClassifier("gcp-function-names", "gcp")
 
 	for _, fn := range found {
-		fmt.Printf("[*] Brute-forcing %d function names in %s\n", len(bruteStrings), fn)
-		// Strip protocol — GetURLBatch will prepend it.
-		fn = strings.TrimPrefix(fn, "http://")
-		fn = strings.TrimPrefix(fn, "https://")
-
-		var c []string
+		s.Printf("[*] Brute-forcing %d function names in %s\n", len(bruteStrings), fn)
+		base := strings.TrimSuffix(fn, "/") + "/"
+		var urls []string
 		for _, b := range bruteStrings {
-			c = append(c, fn+b+"/")
+			urls = append(urls, base+b+"/")
 		}
 
-		GetURLBatch(c, false, printFunctionsResponse2, threads, true)
+		err := s.GetURLBatch(ctx, urls, false, func(result *HttpResult) bool {
+			return classifyName(s, result)
+		}, true)
+		if err != nil {
+			return found, err
+		}
 	}
 
-	StopTimer(start)
+	return found, nil
 }
 
 // ---------------------------------------------------------------------------
-// RunAllGCP is the public entry-point called by main.
+// Registration
 // ---------------------------------------------------------------------------
 
-func RunAllGCP(names []string, cfg *Config) {
-	fmt.Print(gcpBanner)
-
-	checkGCPBuckets(names, cfg.Threads)
-	checkFBRTDB(names, cfg.Threads)
-	checkAppspot(names, cfg.Threads)
-	checkFunctions(names, cfg.BruteData, cfg.QuickScan, cfg.Threads)
+func init() {
+	Register(&check{
+		name:     "gcp-buckets",
+		provider: "gcp",
+		service:  "gcs",
+		title:    "Google buckets",
+		build:    urlCandidates("gcs", "http", ""),
+		classify: ruleClassifier("gcp-buckets", "gcp"),
+		run:      httpRun(true),
+	})
+	Register(&check{
+		name:     "gcp-firebase-rtdb",
+		provider: "gcp",
+		service:  "rtdb",
+		title:    "Google Firebase Realtime Databases",
+		build:    undottedCandidates("rtdb", "https", "/.json"),
+		classify: ruleClassifier("gcp-firebase-rtdb", "gcp"),
+		run:      httpRun(false),
+	})
+	// Firebase apps are not checked by default, matching the original
+	// Python project behaviour.
+	Register(&check{
+		name:     "gcp-firebase-app",
+		provider: "gcp",
+		title:    "Google Firebase Applications",
+		service:  "fbapp",
+		optIn:    true,
+		build:    undottedCandidates("fbapp", "https", ""),
+		classify: ruleClassifier("gcp-firebase-app", "gcp"),
+		run:      httpRun(false),
+	})
+	Register(&check{
+		name:     "gcp-appspot",
+		provider: "gcp",
+		service:  "appspot",
+		title:    "Google App Engine apps",
+		build:    undottedCandidates("appspot", "http", ""),
+		classify: ruleClassifier("gcp-appspot", "gcp"),
+		run:      httpRun(true),
+	})
+	Register(&check{
+		name:     "gcp-functions",
+		provider: "gcp",
+		service:  "functions",
+		title:    "project/zones with Google Cloud Functions",
+		build:    functionCandidates,
+		classify: ruleClassifier("gcp-functions", "gcp"),
+		run:      runFunctions,
+	})
 }
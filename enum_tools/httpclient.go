@@ -0,0 +1,182 @@
+package enum_tools
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// HTTP client
+// ---------------------------------------------------------------------------
+
+// Every request the scanner makes goes through fetch, on one shared
+// transport, so timeouts, retries, proxying and headers apply everywhere.
+
+const (
+	defaultHTTPTimeout = 15 * time.Second
+	defaultMaxBody     = 8192     // probes only need headers / short XML
+	maxListingBody     = 10 << 20 // bucket listings are read in full, up to this
+)
+
+// newTransport builds the HTTP transport shared by all of a scanner's
+// requests. Hostnames are resolved through resolver so a DNS override also
+// applies to HTTP; with a proxy, everything goes through it.
+func newTransport(cfg *Config, resolver *net.Resolver, proxy *url.URL) http.RoundTripper {
+	var proxyFunc func(*http.Request) (*url.URL, error)
+	if proxy != nil {
+		proxyFunc = http.ProxyURL(proxy)
+	}
+	threads := cfg.Threads
+	base := &http.Transport{
+		Proxy:               proxyFunc,
+		MaxIdleConns:        threads * 4,
+		MaxIdleConnsPerHost: threads,
+		MaxConnsPerHost:     threads,
+		IdleConnTimeout:     30 * time.Second,
+		DisableKeepAlives:   false,
+		DialContext: (&net.Dialer{
+			Timeout:   min(5*time.Second, cfg.HTTPTimeout),
+			KeepAlive: 30 * time.Second,
+			Resolver:  resolver,
+		}).DialContext,
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: cfg.InsecureTLS},
+		TLSHandshakeTimeout:   min(5*time.Second, cfg.HTTPTimeout),
+		ResponseHeaderTimeout: min(10*time.Second, cfg.HTTPTimeout),
+	}
+	if cfg.UserAgent == "" && len(cfg.Headers) == 0 {
+		return base
+	}
+	return &headerTransport{base: base, userAgent: cfg.UserAgent, headers: cfg.Headers}
+}
+
+// headerTransport adds the configured User-Agent and extra headers to
+// every request.
+type headerTransport struct {
+	base      http.RoundTripper
+	userAgent string
+	headers   http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		req.Header[k] = v
+	}
+	if t.userAgent != "" {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// newHTTPClient returns a client on transport, following redirects or not.
+func newHTTPClient(transport http.RoundTripper, timeout time.Duration, followRedirects bool) *http.Client {
+	client := &http.Client{Timeout: timeout, Transport: transport}
+	if !followRedirects {
+		client.CheckRedirect = func(_ *http.Request, _ []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	return client
+}
+
+// httpClient returns the scanner's client.
+func (s *Scanner) httpClient(followRedirects bool) *http.Client {
+	if followRedirects {
+		return s.client
+	}
+	return s.clientNoRedirect
+}
+
+// isTransient reports whether a request error is worth retrying: timeouts,
+// resets and connections closed mid-response, but not e.g. unknown hosts.
+func isTransient(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// fetch GETs rawURL, retrying transient connection errors with backoff,
+// and reads at most maxBody bytes of the response.
+func (s *Scanner) fetch(ctx context.Context, rawURL string, followRedirects bool, maxBody int64) (*HttpResult, error) {
+	client := s.httpClient(followRedirects)
+	for attempt := 0; ; attempt++ {
+		// A request on the wire is allowed to finish after ctx is
+		// cancelled, the client timeout bounds it; ctx only decides
+		// whether to retry.
+		req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), http.MethodGet, rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			if attempt >= s.cfg.HTTPRetries || !isTransient(err) || ctx.Err() != nil {
+				return nil, err
+			}
+			if sleepCtx(ctx, retryDelay(attempt, 0)) != nil {
+				return nil, err
+			}
+			continue
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+		resp.Body.Close()
+		if err != nil && len(body) == 0 {
+			return nil, err
+		}
+		return &HttpResult{
+			URL:         resp.Request.URL.String(),
+			StatusCode:  resp.StatusCode,
+			Reason:      extractReason(resp.Status),
+			Body:        string(body),
+			OriginalURL: rawURL,
+			Header:      resp.Header,
+			ctx:         ctx,
+		}, nil
+	}
+}
+
+// ListBucketContents fetches an open bucket URL and returns the full URLs
+// of the keys found.
+func (s *Scanner) ListBucketContents(ctx context.Context, bucket string) ([]string, error) {
+	result, err := s.fetch(ctx, bucket, true, maxListingBody)
+	if err != nil {
+		return nil, err
+	}
+	if throttled, retryAfter := isThrottled(result); throttled {
+		return nil, &throttledError{fmt.Sprintf("%d %s", result.StatusCode, result.Reason), retryAfter}
+	}
+
+	keyRe := regexp.MustCompile(`<(?:Key|Name)>(.*?)</(?:Key|Name)>`)
+	matches := keyRe.FindAllStringSubmatch(result.Body, -1)
+
+	// Strip query parameters before building full URLs.
+	subRe := regexp.MustCompile(`\?.*`)
+	bucket = subRe.ReplaceAllString(bucket, "")
+	if !strings.HasSuffix(bucket, "/") {
+		bucket += "/"
+	}
+
+	files := []string{}
+	for _, m := range matches {
+		files = append(files, bucket+m[1])
+	}
+	return files, nil
+}
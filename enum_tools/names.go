@@ -0,0 +1,69 @@
+package enum_tools
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Name building
+// ---------------------------------------------------------------------------
+
+var bannedNameChars = regexp.MustCompile(`[^a-z0-9.\-]`)
+
+func cleanText(text string) string {
+	return bannedNameChars.ReplaceAllString(strings.ToLower(text), "")
+}
+
+func appendName(name string, names *[]string) {
+	if len(name) <= 63 {
+		*names = append(*names, name)
+	}
+}
+
+// BuildNames combines every keyword with every mutation (appended and
+// prepended, joined directly or with "." / "-") into the list of names the
+// checks probe.
+func BuildNames(keywords, mutations []string) []string {
+	var names []string
+	buildNames(keywords, mutations, func(name string, _ NameOrigin) {
+		appendName(name, &names)
+	})
+	return names
+}
+
+// NameOrigin is the keyword and mutation a name was built from. Mutation
+// is empty for the bare keyword.
+type NameOrigin struct {
+	Keyword  string
+	Mutation string
+}
+
+// NameOrigins maps every name BuildNames returns to where it came from.
+// A name several combinations produce keeps the first.
+func NameOrigins(keywords, mutations []string) map[string]NameOrigin {
+	origins := make(map[string]NameOrigin)
+	buildNames(keywords, mutations, func(name string, o NameOrigin) {
+		if _, ok := origins[name]; !ok && len(name) <= 63 {
+			origins[name] = o
+		}
+	})
+	return origins
+}
+
+func buildNames(keywords, mutations []string, fn func(name string, o NameOrigin)) {
+	for _, keyword := range keywords {
+		base := cleanText(keyword)
+		fn(base, NameOrigin{Keyword: keyword})
+		for _, mutation := range mutations {
+			mut := cleanText(mutation)
+			o := NameOrigin{keyword, mutation}
+			fn(base+mut, o)
+			fn(base+"."+mut, o)
+			fn(base+"-"+mut, o)
+			fn(mut+base, o)
+			fn(mut+"."+base, o)
+			fn(mut+"-"+base, o)
+		}
+	}
+}
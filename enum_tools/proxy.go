@@ -0,0 +1,187 @@
+package enum_tools
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Proxy configuration
+// ---------------------------------------------------------------------------
+
+// ParseProxy validates a -proxy URL: http://, https://, socks5:// or
+// socks5h://, optionally with user:password.
+func ParseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("proxy %q: scheme must be http, https, socks5 or socks5h", u.Redacted())
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy %q has no host", u.Redacted())
+	}
+	if u.Port() == "" {
+		port := map[string]string{"http": "80", "https": "443", "socks5": "1080", "socks5h": "1080"}[u.Scheme]
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+	return u, nil
+}
+
+func isSOCKS(u *url.URL) bool {
+	return u != nil && (u.Scheme == "socks5" || u.Scheme == "socks5h")
+}
+
+// ---------------------------------------------------------------------------
+// SOCKS5 client (RFC 1928, username / password auth per RFC 1929)
+// ---------------------------------------------------------------------------
+
+// socksDialer opens TCP connections through a SOCKS5 proxy. The HTTP
+// transport has its own; this one carries DNS-over-TCP.
+type socksDialer struct {
+	proxy *url.URL
+}
+
+var socksReplies = map[byte]string{
+	1: "general failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// DialContext connects to addr (host:port) through the proxy.
+func (d *socksDialer) DialContext(ctx context.Context, _, addr string) (net.Conn, error) {
+	nd := net.Dialer{Timeout: 5 * time.Second}
+	conn, err := nd.DialContext(ctx, "tcp", d.proxy.Host)
+	if err != nil {
+		return nil, fmt.Errorf("socks proxy: %w", err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	conn.SetDeadline(deadline)
+
+	if err := d.handshake(conn, addr); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("socks proxy: %w", err)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (d *socksDialer) handshake(conn net.Conn, addr string) error {
+	// Method negotiation.
+	methods := []byte{0x00} // no auth
+	if d.proxy.User != nil {
+		methods = append(methods, 0x02) // username / password
+	}
+	if _, err := conn.Write(append([]byte{5, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 5 {
+		return errors.New("not a SOCKS5 server")
+	}
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		if d.proxy.User == nil {
+			return errors.New("proxy requires authentication")
+		}
+		user := d.proxy.User.Username()
+		pass, _ := d.proxy.User.Password()
+		if len(user) > 255 || len(pass) > 255 {
+			return errors.New("proxy credentials too long")
+		}
+		msg := []byte{1, byte(len(user))}
+		msg = append(msg, user...)
+		msg = append(msg, byte(len(pass)))
+		msg = append(msg, pass...)
+		if _, err := conn.Write(msg); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return errors.New("proxy authentication failed")
+		}
+	default:
+		return errors.New("no acceptable authentication method")
+	}
+
+	// CONNECT.
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return fmt.Errorf("bad port in %q", addr)
+	}
+	req := []byte{5, 1, 0}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(append(req, 1), ip4...)
+		} else {
+			req = append(append(req, 4), ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return errors.New("hostname too long")
+		}
+		req = append(append(req, 3, byte(len(host))), host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return err
+	}
+	if head[1] != 0 {
+		msg, ok := socksReplies[head[1]]
+		if !ok {
+			msg = fmt.Sprintf("error %d", head[1])
+		}
+		return fmt.Errorf("connect to %s: %s", addr, msg)
+	}
+	// Skip the bound address.
+	var skip int
+	switch head[3] {
+	case 1:
+		skip = 4
+	case 4:
+		skip = 16
+	case 3:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return err
+		}
+		skip = int(l[0])
+	default:
+		return errors.New("bad address type in reply")
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
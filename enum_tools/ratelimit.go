@@ -0,0 +1,227 @@
+package enum_tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Rate limits
+// ---------------------------------------------------------------------------
+
+// RateLimit is a token-bucket budget: RPS requests per second on average,
+// with up to Burst requests allowed back to back.
+type RateLimit struct {
+	RPS   float64
+	Burst int // 0 = one second's worth of requests
+}
+
+func (rl RateLimit) String() string {
+	return fmt.Sprintf("%g req/s (burst %d)", rl.RPS, rl.burst())
+}
+
+func (rl RateLimit) burst() int {
+	if rl.Burst > 0 {
+		return rl.Burst
+	}
+	return max(1, int(math.Ceil(rl.RPS)))
+}
+
+// ParseRateLimit parses a "target=rps[:burst]" budget such as "aws=50" or
+// "s3.amazonaws.com=20:5". The target is a provider name or, when it
+// contains a dot, a host suffix.
+func ParseRateLimit(spec string) (string, RateLimit, error) {
+	target, value, ok := strings.Cut(spec, "=")
+	if !ok || target == "" {
+		return "", RateLimit{}, fmt.Errorf("rate limit %q must be target=rps[:burst]", spec)
+	}
+	rl, err := parseRPS(value)
+	if err != nil {
+		return "", RateLimit{}, fmt.Errorf("rate limit %q: %w", spec, err)
+	}
+	return strings.ToLower(target), rl, nil
+}
+
+// parseRPS parses "rps[:burst]".
+func parseRPS(value string) (RateLimit, error) {
+	rps, burst, hasBurst := strings.Cut(value, ":")
+	var rl RateLimit
+	var err error
+	if rl.RPS, err = strconv.ParseFloat(rps, 64); err != nil || rl.RPS <= 0 {
+		return RateLimit{}, fmt.Errorf("requests per second must be a positive number")
+	}
+	if hasBurst {
+		if rl.Burst, err = strconv.Atoi(burst); err != nil || rl.Burst <= 0 {
+			return RateLimit{}, fmt.Errorf("burst must be a positive integer")
+		}
+	}
+	return rl, nil
+}
+
+// ---------------------------------------------------------------------------
+// Token bucket
+// ---------------------------------------------------------------------------
+
+// tokenBucket is a classic token bucket. Waiters reserve a token up front,
+// so they are served in order and traffic stays smooth.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rl RateLimit) *tokenBucket {
+	burst := float64(rl.burst())
+	return &tokenBucket{rate: rl.RPS, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait blocks until a token is available or ctx is done.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	delay := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	if err := sleepCtx(ctx, delay); err != nil {
+		// Hand the reservation back to the other waiters.
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// take takes a token if one is available, without waiting.
+func (b *tokenBucket) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// ---------------------------------------------------------------------------
+// Compatibility limiter (-rl / -rls)
+// ---------------------------------------------------------------------------
+
+// rateLimiter pauses every worker for sleep after each threshold HTTP
+// requests, the historical -rl / -rls behaviour.
+type rateLimiter struct {
+	mu        sync.Mutex
+	count     int64         // total HTTP requests made (across all batches)
+	threshold int64         // pause every N requests (0 = disabled)
+	sleep     time.Duration // duration of the pause
+	resumeAt  time.Time     // end of the current pause
+}
+
+func newRateLimiter(threshold int, sleep time.Duration) *rateLimiter {
+	return &rateLimiter{threshold: int64(threshold), sleep: sleep}
+}
+
+// check must be called before every HTTP request. When the cumulative
+// request count crosses a multiple of the threshold all workers wait until
+// the pause is over. Returns early with ctx.Err() when ctx is cancelled.
+func (rl *rateLimiter) check(ctx context.Context, s *Scanner) error {
+	if rl.threshold <= 0 {
+		return nil
+	}
+	rl.mu.Lock()
+	rl.count++
+	if rl.count%rl.threshold == 0 {
+		rl.resumeAt = time.Now().Add(rl.sleep)
+		s.Printf("\n    [*] Rate limit: %d requests done, sleeping %v...\n", rl.count, rl.sleep)
+	}
+	wait := time.Until(rl.resumeAt)
+	rl.mu.Unlock()
+
+	if wait > 0 {
+		return sleepCtx(ctx, wait)
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Request limiter
+// ---------------------------------------------------------------------------
+
+// hostBucket is the budget shared by every host under a suffix.
+type hostBucket struct {
+	suffix string
+	bucket *tokenBucket
+}
+
+// requestLimiter combines the compatibility limiter with the global,
+// per-provider and per-host-suffix token buckets. A request waits for
+// every budget that applies to it.
+type requestLimiter struct {
+	compat    *rateLimiter
+	global    *tokenBucket
+	providers map[string]*tokenBucket
+	hosts     []hostBucket // longest suffix first
+}
+
+func newRequestLimiter(cfg *Config) *requestLimiter {
+	l := &requestLimiter{
+		compat:    newRateLimiter(cfg.RateLimitReqs, cfg.RateLimitSleep),
+		providers: make(map[string]*tokenBucket),
+	}
+	if cfg.RateLimit.RPS > 0 {
+		l.global = newTokenBucket(cfg.RateLimit)
+	}
+	for provider, rl := range cfg.ProviderRateLimits {
+		l.providers[provider] = newTokenBucket(rl)
+	}
+	for suffix, rl := range cfg.HostRateLimits {
+		l.hosts = append(l.hosts, hostBucket{strings.TrimPrefix(strings.ToLower(suffix), "."), newTokenBucket(rl)})
+	}
+	sort.Slice(l.hosts, func(i, j int) bool { return len(l.hosts[i].suffix) > len(l.hosts[j].suffix) })
+	return l
+}
+
+// hostBucket returns the bucket of the longest suffix matching host.
+func (l *requestLimiter) hostBucket(host string) *tokenBucket {
+	host = strings.ToLower(host)
+	for _, hb := range l.hosts {
+		if host == hb.suffix || strings.HasSuffix(host, "."+hb.suffix) {
+			return hb.bucket
+		}
+	}
+	return nil
+}
+
+// wait blocks until a request from provider to host fits every budget.
+func (l *requestLimiter) wait(ctx context.Context, s *Scanner, provider, host string) error {
+	if err := l.compat.check(ctx, s); err != nil {
+		return err
+	}
+	if err := l.global.wait(ctx); err != nil {
+		return err
+	}
+	if err := l.providers[provider].wait(ctx); err != nil {
+		return err
+	}
+	return l.hostBucket(host).wait(ctx)
+}
@@ -0,0 +1,149 @@
+package enum_tools
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		spec   string
+		target string
+		want   RateLimit
+		fail   bool
+	}{
+		{"aws=50", "aws", RateLimit{RPS: 50}, false},
+		{"AWS=0.5", "aws", RateLimit{RPS: 0.5}, false},
+		{"s3.amazonaws.com=20:5", "s3.amazonaws.com", RateLimit{RPS: 20, Burst: 5}, false},
+		{"aws", "", RateLimit{}, true},
+		{"=50", "", RateLimit{}, true},
+		{"aws=0", "", RateLimit{}, true},
+		{"aws=-1", "", RateLimit{}, true},
+		{"aws=fast", "", RateLimit{}, true},
+		{"aws=50:0", "", RateLimit{}, true},
+		{"aws=50:1.5", "", RateLimit{}, true},
+	}
+	for _, tt := range tests {
+		target, rl, err := ParseRateLimit(tt.spec)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("%s: got %s %v, want an error", tt.spec, target, rl)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.spec, err)
+		} else if target != tt.target || rl != tt.want {
+			t.Errorf("%s: got %s %v, want %s %v", tt.spec, target, rl, tt.target, tt.want)
+		}
+	}
+}
+
+func TestRateLimitBurst(t *testing.T) {
+	for _, tt := range []struct {
+		rl   RateLimit
+		want int
+	}{
+		{RateLimit{RPS: 10}, 10},
+		{RateLimit{RPS: 2.5}, 3},
+		{RateLimit{RPS: 0.1}, 1},
+		{RateLimit{RPS: 10, Burst: 4}, 4},
+	} {
+		if got := tt.rl.burst(); got != tt.want {
+			t.Errorf("%+v: burst %d, want %d", tt.rl, got, tt.want)
+		}
+	}
+}
+
+func TestTokenBucketTake(t *testing.T) {
+	b := newTokenBucket(RateLimit{RPS: 1, Burst: 3})
+	for i := 0; i < 3; i++ {
+		if !b.take() {
+			t.Fatalf("take %d of the burst failed", i+1)
+		}
+	}
+	if b.take() {
+		t.Fatal("took a token past the burst")
+	}
+	// Two seconds later two tokens are back, and no more than the burst
+	// ever accumulates.
+	b.last = b.last.Add(-2 * time.Second)
+	if !b.take() || !b.take() || b.take() {
+		t.Error("refill after 2s: want exactly 2 tokens")
+	}
+	b.last = b.last.Add(-time.Hour)
+	n := 0
+	for b.take() {
+		n++
+	}
+	if n != 3 {
+		t.Errorf("%d tokens after an hour, want the burst of 3", n)
+	}
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	ctx := context.Background()
+	var nilBucket *tokenBucket
+	if err := nilBucket.wait(ctx); err != nil {
+		t.Fatalf("nil bucket: %v", err)
+	}
+
+	b := newTokenBucket(RateLimit{RPS: 50, Burst: 2})
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := b.wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The burst passes at once, the next two are 20ms apart.
+	if took := time.Since(start); took < 35*time.Millisecond || took > time.Second {
+		t.Errorf("4 waits at 50 req/s, burst 2: took %v, want about 40ms", took)
+	}
+
+	// A cancelled waiter hands its reservation back.
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	before := b.tokens
+	if err := b.wait(cancelled); err == nil {
+		t.Fatal("cancelled wait: no error")
+	}
+	if b.tokens < before {
+		t.Errorf("tokens %g after a cancelled wait, had %g", b.tokens, before)
+	}
+}
+
+func TestHostBucket(t *testing.T) {
+	l := newRequestLimiter(&Config{HostRateLimits: map[string]RateLimit{
+		"amazonaws.com":     {RPS: 100},
+		".s3.amazonaws.com": {RPS: 10},
+		"Windows.net":       {RPS: 50},
+	}})
+	s3 := l.hostBucket("acme.s3.amazonaws.com")
+	aws := l.hostBucket("acme.awsapps.amazonaws.com")
+	azure := l.hostBucket("windows.net")
+	tests := []struct {
+		host string
+		want *tokenBucket
+	}{
+		{"acme.s3.amazonaws.com", s3},
+		{"ACME.S3.AMAZONAWS.COM", s3},
+		{"s3.amazonaws.com", s3},
+		{"acme.awsapps.amazonaws.com", aws},
+		{"amazonaws.com", aws},
+		{"notamazonaws.com", nil},
+		{"acme.blob.core.windows.net", azure},
+		{"storage.googleapis.com", nil},
+	}
+	if s3 == nil || aws == nil || azure == nil || s3 == aws {
+		t.Fatal("suffixes share a bucket")
+	}
+	if s3.rate != 10 || aws.rate != 100 {
+		t.Errorf("rates %g and %g, want 10 and 100", s3.rate, aws.rate)
+	}
+	for _, tt := range tests {
+		if got := l.hostBucket(tt.host); got != tt.want {
+			t.Errorf("%s: wrong bucket", tt.host)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package enum_tools
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Reports
+// ---------------------------------------------------------------------------
+
+// reportHTML is the page template of HTML reports. Styles and scripts are
+// inline so the report is a single file.
+//
+//go:embed report.html
+var reportHTML string
+
+// ReportInfo describes the scan a report covers.
+type ReportInfo struct {
+	RunID       string
+	Mode        string   // "keywords", "takeover" or "attribution"
+	Keywords    []string // keyword scans only
+	Mutations   int      // mutations applied to each keyword
+	Targets     int      // names, subdomains or assets scanned
+	Regions     map[string][]string
+	Started     time.Time
+	Duration    time.Duration
+	Interrupted bool
+	Timings     []CheckTiming
+	Stats       Stats
+	Errors      []string // notable errors: the scan's own, dropped resolvers
+}
+
+// accessOrder ranks access levels for reports, most exposed first.
+var accessOrder = map[string]int{"public": 0, "unclaimed": 1, "protected": 2, "disabled": 3}
+
+func accessRank(access string) int {
+	if r, ok := accessOrder[access]; ok {
+		return r
+	}
+	return len(accessOrder)
+}
+
+// accessClass is the colour class of an access level, as FmtOutput
+// colours it.
+func accessClass(access string) string {
+	switch access {
+	case "public":
+		return "public"
+	case "protected":
+		return "protected"
+	case "disabled", "unclaimed":
+		return "disabled"
+	}
+	return "other"
+}
+
+// sortFindings orders findings by access level, then target.
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		ri, rj := accessRank(findings[i].Access), accessRank(findings[j].Access)
+		if ri != rj {
+			return ri < rj
+		}
+		return findings[i].Target < findings[j].Target
+	})
+}
+
+// evidence summarises the response or DNS answer behind a finding.
+func evidence(f Finding) string {
+	switch {
+	case f.HTTP != nil:
+		return fmt.Sprintf("HTTP %d %s", f.HTTP.Status, f.HTTP.Reason)
+	case f.DNS != nil:
+		parts := []string{f.DNS.Rcode}
+		if len(f.DNS.CNAMEs) > 0 {
+			parts = append(parts, "CNAME "+strings.Join(f.DNS.CNAMEs, " -> "))
+		}
+		if len(f.DNS.IPs) > 0 {
+			parts = append(parts, strings.Join(f.DNS.IPs, ", "))
+		}
+		return "DNS " + strings.Join(parts, ", ")
+	}
+	return ""
+}
+
+// reportGroup is the findings of one provider's service.
+type reportGroup struct {
+	Provider string
+	Service  string
+	Findings []Finding
+}
+
+type reportCount struct {
+	Access string
+	Class  string
+	Count  int
+}
+
+type reportTiming struct {
+	CheckTiming
+	Findings int
+}
+
+type reportPage struct {
+	ReportInfo
+	Generated time.Time
+	Total     int
+	Counts    []reportCount
+	Groups    []reportGroup
+	Timings   []reportTiming
+}
+
+// groupFindings groups findings by provider and service (the check, for
+// findings without a service), in provider order.
+func groupFindings(findings []Finding) []reportGroup {
+	index := make(map[[2]string]int)
+	var groups []reportGroup
+	for _, f := range findings {
+		service := f.Service
+		if service == "" {
+			service = f.Check
+		}
+		key := [2]string{f.Platform, service}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, reportGroup{Provider: f.Platform, Service: service})
+		}
+		groups[i].Findings = append(groups[i].Findings, f)
+	}
+	rank := map[string]int{"aws": 0, "azure": 1, "gcp": 2, "custom": 3}
+	sort.SliceStable(groups, func(i, j int) bool {
+		ri, ok := rank[groups[i].Provider]
+		if !ok {
+			ri = len(rank)
+		}
+		rj, ok := rank[groups[j].Provider]
+		if !ok {
+			rj = len(rank)
+		}
+		if ri != rj {
+			return ri < rj
+		}
+		return groups[i].Service < groups[j].Service
+	})
+	for _, g := range groups {
+		sortFindings(g.Findings)
+	}
+	return groups
+}
+
+// countFindings counts findings per access level, most exposed first.
+func countFindings(findings []Finding) []reportCount {
+	counts := make(map[string]int)
+	for _, f := range findings {
+		counts[f.Access]++
+	}
+	var out []reportCount
+	for access, n := range counts {
+		out = append(out, reportCount{access, accessClass(access), n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		ri, rj := accessRank(out[i].Access), accessRank(out[j].Access)
+		if ri != rj {
+			return ri < rj
+		}
+		return out[i].Access < out[j].Access
+	})
+	return out
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"accessClass":  accessClass,
+	"evidence":     evidence,
+	"providerName": reportProviderName,
+	"duration": func(d time.Duration) string {
+		return d.Round(time.Millisecond).String()
+	},
+	"join":   strings.Join,
+	"listed": func(f Finding) bool { return f.Files != nil },
+}).Parse(reportHTML))
+
+// WriteHTMLReport writes a self-contained HTML report of findings to path:
+// the scan's parameters and timings, then the findings grouped by
+// provider and service in sortable, filterable tables.
+func WriteHTMLReport(path string, info ReportInfo, findings []Finding) error {
+	page := reportPage{
+		ReportInfo: info,
+		Generated:  time.Now(),
+		Total:      len(findings),
+		Counts:     countFindings(findings),
+		Groups:     groupFindings(append([]Finding(nil), findings...)),
+	}
+	perCheck := make(map[string]int)
+	for _, f := range findings {
+		perCheck[f.Check]++
+	}
+	for _, t := range info.Timings {
+		page.Timings = append(page.Timings, reportTiming{t, perCheck[t.Check]})
+	}
+
+	var b strings.Builder
+	if err := reportTemplate.Execute(&b, page); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
@@ -0,0 +1,301 @@
+package enum_tools
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Response rules
+// ---------------------------------------------------------------------------
+
+// rulesJSON holds the built-in classification of every HTTP check's
+// responses.
+//
+//go:embed rules.json
+var rulesJSON []byte
+
+const rulesVersion = 1
+
+// Rules classify HTTP responses into findings. Each check has a named
+// ruleset; its rules are tried in order and the first one matching the
+// response decides. A rule with no message, warning or breakout marks the
+// response as "not found".
+type Rules struct {
+	sets map[string][]*rule
+}
+
+type rulesFile struct {
+	Version  int                `json:"version"`
+	Rulesets map[string][]*rule `json:"rulesets"`
+}
+
+// rule matches a response when all of its conditions hold.
+type rule struct {
+	Status  []statusPattern   `json:"status,omitempty"`  // any of these codes, e.g. 404 or "5xx"
+	Reason  string            `json:"reason,omitempty"`  // regexp on the reason phrase
+	Body    string            `json:"body,omitempty"`    // regexp on the body
+	Text    string            `json:"text,omitempty"`    // regexp on the reason or the body
+	Headers map[string]string `json:"headers,omitempty"` // regexps on header values
+	URL     string            `json:"url,omitempty"`     // regexp on the URL after redirects
+	CNAME   string            `json:"cname,omitempty"`   // regexp on a CNAME of the chain (DNS templates)
+
+	Msg      string `json:"msg,omitempty"`
+	Access   string `json:"access,omitempty"`   // public, protected or disabled
+	Severity string `json:"severity,omitempty"` // info, low, medium or high
+	Target   string `json:"target,omitempty"`   // default "{url}"
+	List     string `json:"list,omitempty"`     // URL whose keys are listed with the finding
+	Remember string `json:"remember,omitempty"` // keeps the URL for the check's second stage
+	Warn     string `json:"warn,omitempty"`     // printed when the rule matches
+	Breakout bool   `json:"breakout,omitempty"` // stops the rest of the batch
+
+	reason, body, text, url, cname *regexp.Regexp
+	headers                        map[string]*regexp.Regexp
+}
+
+// statusPattern is a status code with optional "x" digits, e.g. "5xx".
+type statusPattern string
+
+func (p *statusPattern) UnmarshalJSON(data []byte) error {
+	var code int
+	if err := json.Unmarshal(data, &code); err == nil {
+		*p = statusPattern(strconv.Itoa(code))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("status must be a code or a pattern like \"5xx\"")
+	}
+	s = strings.ToLower(s)
+	if len(s) != 3 || strings.Trim(s, "0123456789x") != "" {
+		return fmt.Errorf("bad status pattern %q", s)
+	}
+	*p = statusPattern(s)
+	return nil
+}
+
+func (p statusPattern) matches(code int) bool {
+	c := strconv.Itoa(code)
+	if len(c) != len(p) {
+		return false
+	}
+	for i := range c {
+		if p[i] != 'x' && p[i] != c[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var defaultRules = func() *Rules {
+	r, err := parseRules(rulesJSON)
+	if err != nil {
+		panic("enum_tools: built-in rules: " + err.Error())
+	}
+	return r
+}()
+
+// DefaultRules returns the built-in rules.
+func DefaultRules() *Rules {
+	return defaultRules
+}
+
+// LoadRules returns the built-in rules with the rulesets of a user rules
+// file (same format as the built-in rules.json) put in front, so its rules
+// are tried first. A ruleset ending with a catch-all rule replaces the
+// built-in one entirely.
+func LoadRules(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read rules file: %w", err)
+	}
+	user, err := parseRules(data)
+	if err != nil {
+		return nil, fmt.Errorf("rules file %s: %w", path, err)
+	}
+	merged := &Rules{sets: make(map[string][]*rule)}
+	for name, rules := range defaultRules.sets {
+		merged.sets[name] = rules
+	}
+	for name, rules := range user.sets {
+		if _, ok := defaultRules.sets[name]; !ok {
+			return nil, fmt.Errorf("rules file %s: unknown ruleset %q", path, name)
+		}
+		merged.sets[name] = append(append([]*rule{}, rules...), defaultRules.sets[name]...)
+	}
+	return merged, nil
+}
+
+func parseRules(data []byte) (*Rules, error) {
+	var f rulesFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if f.Version != rulesVersion {
+		return nil, fmt.Errorf("unsupported version %d", f.Version)
+	}
+	for name, rules := range f.Rulesets {
+		for i, rl := range rules {
+			if err := rl.compile(); err != nil {
+				return nil, fmt.Errorf("%s rule %d: %w", name, i+1, err)
+			}
+		}
+	}
+	return &Rules{sets: f.Rulesets}, nil
+}
+
+// compile checks a rule and compiles its regexps.
+func (rl *rule) compile() error {
+	var err error
+	for _, re := range []struct {
+		expr string
+		dst  **regexp.Regexp
+	}{{rl.Reason, &rl.reason}, {rl.Body, &rl.body}, {rl.Text, &rl.text}, {rl.URL, &rl.url}, {rl.CNAME, &rl.cname}} {
+		if re.expr == "" {
+			continue
+		}
+		if *re.dst, err = regexp.Compile(re.expr); err != nil {
+			return err
+		}
+	}
+	rl.headers = make(map[string]*regexp.Regexp)
+	for name, expr := range rl.Headers {
+		if rl.headers[name], err = regexp.Compile(expr); err != nil {
+			return err
+		}
+	}
+
+	switch rl.Access {
+	case "", "public", "protected", "disabled":
+	default:
+		return fmt.Errorf("unknown access %q", rl.Access)
+	}
+	switch rl.Severity {
+	case "", "info", "low", "medium", "high":
+	default:
+		return fmt.Errorf("unknown severity %q", rl.Severity)
+	}
+	if rl.Msg == "" && (rl.Access != "" || rl.Severity != "" || rl.List != "" || rl.Remember != "") {
+		return fmt.Errorf("access, severity, list and remember need a msg")
+	}
+	if rl.Msg != "" && rl.Access == "" {
+		return fmt.Errorf("msg needs an access")
+	}
+	return nil
+}
+
+func (rl *rule) matches(result *HttpResult) bool {
+	if len(rl.Status) > 0 {
+		ok := false
+		for _, p := range rl.Status {
+			ok = ok || p.matches(result.StatusCode)
+		}
+		if !ok {
+			return false
+		}
+	}
+	if rl.reason != nil && !rl.reason.MatchString(result.Reason) {
+		return false
+	}
+	if rl.body != nil && !rl.body.MatchString(result.Body) {
+		return false
+	}
+	if rl.text != nil && !rl.text.MatchString(result.Reason) && !rl.text.MatchString(result.Body) {
+		return false
+	}
+	if rl.url != nil && !rl.url.MatchString(result.URL) {
+		return false
+	}
+	for name, re := range rl.headers {
+		if !re.MatchString(result.Header.Get(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesDNS reports whether a resolved name's answer satisfies the rule's
+// cname condition, the only one a DNS answer has.
+func (rl *rule) matchesDNS(ans *DNSAnswer) bool {
+	if rl.cname == nil {
+		return true
+	}
+	for _, name := range ans.CNAMEs {
+		if rl.cname.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// expand fills the {url}, {original}, {status} and {reason} placeholders.
+func expand(tmpl string, result *HttpResult) string {
+	return strings.NewReplacer(
+		"{url}", result.URL,
+		"{original}", result.OriginalURL,
+		"{status}", strconv.Itoa(result.StatusCode),
+		"{reason}", result.Reason,
+	).Replace(tmpl)
+}
+
+// classify applies a ruleset to a response, reporting what it finds
+// through the scanner. Returns true to break out.
+func (r *Rules) classify(s *Scanner, set, provider string, result *HttpResult) bool {
+	return applyRules(s, r.sets[set], provider, result)
+}
+
+// applyRules reports a response as the first of rules matching it says.
+// Returns true to break out.
+func applyRules(s *Scanner, rules []*rule, provider string, result *HttpResult) bool {
+	for _, rl := range rules {
+		if !rl.matches(result) {
+			continue
+		}
+		if rl.Warn != "" {
+			s.Printf("    %s\n", expand(rl.Warn, result))
+		}
+		if rl.Msg != "" {
+			target := rl.Target
+			if target == "" {
+				target = "{url}"
+			}
+			data := OutputData{
+				Platform: provider,
+				Msg:      rl.Msg,
+				Target:   expand(target, result),
+				Access:   rl.Access,
+				Severity: rl.Severity,
+			}
+			if f := httpFinding(data, result); rl.List != "" {
+				ctx := result.ctx
+				if ctx == nil {
+					ctx = context.Background() // a response classified outside of a Run
+				}
+				s.emitListing(ctx, f, expand(rl.List, result))
+			} else {
+				s.emitFinding(f)
+			}
+			if rl.Remember != "" {
+				s.cp.remember(rl.Remember, result.URL)
+			}
+		}
+		return rl.Breakout
+	}
+	return false
+}
+
+// ruleClassifier returns a check classifier applying the named ruleset.
+func ruleClassifier(set, provider string) func(*Scanner, *HttpResult) bool {
+	if _, ok := defaultRules.sets[set]; !ok {
+		panic("enum_tools: no built-in ruleset " + set)
+	}
+	return func(s *Scanner, result *HttpResult) bool {
+		return s.cfg.Rules.classify(s, set, provider, result)
+	}
+}
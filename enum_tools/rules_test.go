@@ -0,0 +1,178 @@
+package enum_tools
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// parseRule parses and compiles a single rule.
+func parseRule(t *testing.T, src string) *rule {
+	t.Helper()
+	var rl rule
+	if err := json.Unmarshal([]byte(src), &rl); err != nil {
+		t.Fatal(err)
+	}
+	if err := rl.compile(); err != nil {
+		t.Fatal(err)
+	}
+	return &rl
+}
+
+func TestRuleMatches(t *testing.T) {
+	result := &HttpResult{
+		URL:        "https://accounts.google.com/ServiceLogin",
+		StatusCode: 503,
+		Reason:     "Slow Down",
+		Body:       "<Error><Code>SlowDown</Code></Error>",
+		Header:     http.Header{"Server": {"AmazonS3"}},
+	}
+	tests := []struct {
+		rule string
+		want bool
+	}{
+		{`{}`, true},
+		{`{"status": [503]}`, true},
+		{`{"status": [404, 503]}`, true},
+		{`{"status": ["5xx"]}`, true},
+		{`{"status": ["5x1"]}`, false},
+		{`{"status": [404]}`, false},
+		{`{"reason": "^Slow"}`, true},
+		{`{"reason": "SlowDown"}`, false},
+		{`{"body": "<Code>SlowDown</Code>"}`, true},
+		{`{"text": "SlowDown"}`, true},  // in the body
+		{`{"text": "Slow Down"}`, true}, // in the reason
+		{`{"text": "Throttled"}`, false},
+		{`{"headers": {"Server": "^AmazonS3$"}}`, true},
+		{`{"headers": {"X-Missing": "."}}`, false},
+		{`{"url": "accounts\\.google\\.com"}`, true},
+		{`{"status": [503], "reason": "Bad Request"}`, false}, // every condition must hold
+		{`{"status": [503], "reason": "Slow Down"}`, true},
+	}
+	for _, tt := range tests {
+		if got := parseRule(t, tt.rule).matches(result); got != tt.want {
+			t.Errorf("%s: matches %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name, json, err string
+	}{
+		{"valid", `{"version": 1, "rulesets": {"aws-s3": [{"status": [404]}, {"msg": "Open", "access": "public"}]}}`, ""},
+		{"version", `{"version": 2, "rulesets": {}}`, "unsupported version"},
+		{"status", `{"version": 1, "rulesets": {"aws-s3": [{"status": ["5xxx"]}]}}`, "bad status pattern"},
+		{"regexp", `{"version": 1, "rulesets": {"aws-s3": [{"reason": "("}]}}`, "aws-s3 rule 1"},
+		{"header regexp", `{"version": 1, "rulesets": {"aws-s3": [{"status": [404]}, {"headers": {"Server": "["}}]}}`, "aws-s3 rule 2"},
+		{"access", `{"version": 1, "rulesets": {"aws-s3": [{"msg": "Open", "access": "open"}]}}`, "unknown access"},
+		{"severity", `{"version": 1, "rulesets": {"aws-s3": [{"msg": "Open", "access": "public", "severity": "critical"}]}}`, "unknown severity"},
+		{"msg without access", `{"version": 1, "rulesets": {"aws-s3": [{"msg": "Open"}]}}`, "msg needs an access"},
+		{"list without msg", `{"version": 1, "rulesets": {"aws-s3": [{"list": "{url}"}]}}`, "need a msg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseRules([]byte(tt.json))
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatal(err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	r, err := LoadRules(write("rules.json", `{"version": 1, "rulesets": {"aws-s3": [{"status": [418], "msg": "Teapot", "access": "public"}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s3 := r.sets["aws-s3"]
+	if n := len(defaultRules.sets["aws-s3"]); len(s3) != n+1 {
+		t.Fatalf("%d aws-s3 rules, want %d", len(s3), n+1)
+	}
+	if s3[0].Msg != "Teapot" {
+		t.Errorf("user rule not tried first: %+v", s3[0])
+	}
+	if len(r.sets["gcp-buckets"]) != len(defaultRules.sets["gcp-buckets"]) {
+		t.Error("other rulesets changed")
+	}
+	if defaultRules.sets["aws-s3"][0].Msg == "Teapot" {
+		t.Error("built-in rules changed")
+	}
+
+	if _, err := LoadRules(write("unknown.json", `{"version": 1, "rulesets": {"aws-s4": [{"status": [404]}]}}`)); err == nil || !strings.Contains(err.Error(), "unknown ruleset") {
+		t.Errorf("unknown ruleset: %v", err)
+	}
+	if _, err := LoadRules(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("missing file: no error")
+	}
+}
+
+func TestScanRules(t *testing.T) {
+	sc := &Scenario{
+		Address: "127.0.0.1",
+		Resources: []SimResource{
+			{URL: "acme.s3.amazonaws.com", Status: 200, Reason: "OK"},
+			{URL: "acme-backup.s3.amazonaws.com", Status: 403, Reason: "Forbidden"},
+			{URL: "acme-dev.s3.amazonaws.com", Status: 400, Reason: "Bad Request"},
+			{URL: "acme-prod.s3.amazonaws.com", Status: 400, Reason: "Invalid Bucket Name"},
+			{URL: "acmeprod.s3.amazonaws.com", Status: 404, Reason: "Not Found"},
+		},
+	}
+	findings, out, port := simScan(t, sc, []string{"aws-s3"}, []string{"backup", "dev", "prod"}, "")
+
+	tests := []struct {
+		name   string
+		access string // "" for no finding
+		warn   bool
+	}{
+		{"acme", "public", false}, // 200, listed
+		{"acme-backup", "protected", false},
+		{"acme-dev", "", false}, // reason rule matches first
+		{"acme-prod", "", true}, // 400 with another reason falls through
+		{"acmeprod", "", false}, // 404
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := "http://" + tt.name + ".s3.amazonaws.com:" + port
+			var found []Finding
+			for _, f := range findings {
+				if f.Target == url {
+					found = append(found, f)
+				}
+			}
+			switch {
+			case tt.access == "" && len(found) > 0:
+				t.Errorf("unexpected finding %+v", found[0].OutputData)
+			case tt.access != "" && len(found) != 1:
+				t.Fatalf("%d findings, want 1", len(found))
+			case tt.access != "" && found[0].Access != tt.access:
+				t.Errorf("access %q, want %q", found[0].Access, tt.access)
+			}
+			if warned := strings.Contains(out, "Unknown status codes being received from "+url); warned != tt.warn {
+				t.Errorf("warned %v, want %v", warned, tt.warn)
+			}
+		})
+	}
+
+	// The open bucket is listed, even when empty.
+	for _, f := range findings {
+		if f.Access == "public" && f.Files == nil {
+			t.Errorf("%s: bucket not listed", f.Target)
+		}
+	}
+}
@@ -0,0 +1,474 @@
+package enum_tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Scanner
+// ---------------------------------------------------------------------------
+
+// Scanner runs a set of checks and reports what it finds through a callback
+// or a channel instead of printing it. Each Scanner owns all of its state,
+// so several scans can run independently in one process.
+type Scanner struct {
+	cfg     Config
+	checks  []Check
+	limiter *requestLimiter
+
+	client           *http.Client              // shared transport, follows redirects
+	clientNoRedirect *http.Client              // same transport, doesn't follow
+	adaptive         map[string]*adaptiveLimit // per-provider concurrency, lowered on throttling
+	stats            scanStats
+
+	dns         dnsResolver
+	cache       *DNSCache
+	resolverID  string // the resolver configuration, keying cached answers
+	customNS    bool
+	dnsCheck    sync.Once // nameservers validated before the first lookup
+	dnsCheckErr error
+	zoneMu      sync.Mutex
+	zones       map[string]*wildcard // probed zones, nil when not wildcarded
+
+	// The fields below are guarded by mu. Run and the sessions set them
+	// before starting any check, whose goroutines then read current and cp
+	// without locking; a Scanner runs one scan at a time.
+	mu       sync.Mutex
+	emitMu   sync.Mutex // serialises calls to emit, outside of mu
+	emit     func(Finding)
+	current  string          // name of the check currently running
+	provider string          // and its provider, for the rate limits
+	service  string          // and its endpoint service, if any
+	names    map[string]bool // names of the active Run, to attribute findings
+	runID    string          // ID of the active or last run
+	timings  []CheckTiming   // checks run so far
+	cp       *checkpoint     // progress of the active Run, nil before the first
+}
+
+// NewScanner validates cfg and prepares a Scanner.
+func NewScanner(cfg Config) (*Scanner, error) {
+	if cfg.Threads <= 0 {
+		cfg.Threads = 25
+	}
+	if cfg.Output == nil {
+		cfg.Output = io.Discard
+	}
+	if cfg.SaveInterval <= 0 {
+		cfg.SaveInterval = 30 * time.Second
+	}
+	if cfg.HTTPTimeout <= 0 {
+		cfg.HTTPTimeout = defaultHTTPTimeout
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = defaultMaxBody
+	}
+	if cfg.AuthoritativeQPS <= 0 {
+		cfg.AuthoritativeQPS = 20
+	}
+	if cfg.DNSCache == nil {
+		cfg.DNSCache = NewDNSCache()
+	}
+	if cfg.Rules == nil {
+		cfg.Rules = DefaultRules()
+	}
+
+	checks, err := SelectChecks(cfg.Checks, cfg.SkipChecks)
+	if err != nil {
+		return nil, err
+	}
+
+	for provider := range cfg.ProviderRateLimits {
+		if _, ok := providerBanners[provider]; !ok {
+			return nil, fmt.Errorf("rate limit for unknown provider %q", provider)
+		}
+	}
+
+	s := &Scanner{
+		cfg:      cfg,
+		checks:   checks,
+		limiter:  newRequestLimiter(&cfg),
+		adaptive: newAdaptiveLimits(checks, cfg.Threads),
+		zones:    make(map[string]*wildcard),
+		cache:    cfg.DNSCache,
+	}
+
+	var proxy *url.URL
+	if cfg.Proxy != "" {
+		if proxy, err = ParseProxy(cfg.Proxy); err != nil {
+			return nil, err
+		}
+	}
+	var dnsDialer *socksDialer
+	if cfg.ProxyDNS {
+		if !isSOCKS(proxy) {
+			return nil, errors.New("DNS can only be proxied through a socks5 proxy")
+		}
+		dnsDialer = &socksDialer{proxy}
+	}
+
+	// Default: the built-in client asking the nameservers of
+	// /etc/resolv.conf (see systemNameservers), or the platform resolver
+	// where there are none, e.g. on Windows.
+	// Custom: only when the caller explicitly sets a non-default nameserver
+	// or a nameserver file, or DNS goes through the proxy (the platform
+	// resolver can't).
+	// A DNS override (a local stand-in resolver) takes precedence.
+	if cfg.DNSOverride != "" {
+		if s.dns, err = newDNSClient([]string{cfg.DNSOverride}, dnsDialer, cfg.InsecureTLS, 3*time.Second); err != nil {
+			return nil, err
+		}
+		s.resolverID = "override=" + cfg.DNSOverride
+		s.customNS = true
+	} else if useCustomNS(cfg.Nameserver, cfg.NameserverFile) || dnsDialer != nil {
+		nsList := []string{cfg.Nameserver}
+		if cfg.NameserverFile != "" {
+			if nsList, err = ReadNameservers(cfg.NameserverFile); err != nil {
+				return nil, err
+			}
+		} else if cfg.Nameserver == "" {
+			nsList = []string{"1.1.1.1"}
+		}
+		if s.dns, err = newDNSClient(nsList, dnsDialer, cfg.InsecureTLS, 3*time.Second); err != nil {
+			return nil, err
+		}
+		s.resolverID = "ns=" + strings.Join(nsList, ",")
+		s.customNS = true
+	} else if nsList := systemNameservers(); len(nsList) > 0 {
+		if s.dns, err = newDNSClient(nsList, nil, false, 5*time.Second); err != nil {
+			return nil, err
+		}
+		s.resolverID = "system=" + strings.Join(nsList, ",")
+	} else {
+		s.dns = systemResolver{5 * time.Second}
+		s.resolverID = "system"
+	}
+	if dnsDialer != nil {
+		s.resolverID += ";proxy=" + proxy.Host
+	}
+
+	if c, ok := s.dns.(*dnsClient); ok {
+		c.warn = s.Printf
+		if cfg.Authoritative {
+			s.dns = newAuthResolver(c, RateLimit{RPS: cfg.AuthoritativeQPS}, cfg.DNSOverride)
+			s.resolverID += ";authoritative"
+		}
+	} else if cfg.Authoritative {
+		return nil, errors.New("authoritative mode needs a nameserver to find the zones' servers (-ns)")
+	}
+
+	// Only route HTTP dials through the resolver when it points at a stand-in;
+	// otherwise keep the system resolver as before.
+	var dialResolver *net.Resolver
+	if cfg.DNSOverride != "" {
+		dialResolver = newResolver([]string{cfg.DNSOverride}, dnsDialer)
+	}
+	transport := newTransport(&s.cfg, dialResolver, proxy)
+	s.client = newHTTPClient(transport, cfg.HTTPTimeout, true)
+	s.clientNoRedirect = newHTTPClient(transport, cfg.HTTPTimeout, false)
+
+	return s, nil
+}
+
+// Config returns a copy of the configuration the scanner was built with.
+// Setting its fields has no effect on the scanner; maps and pointers are
+// shared with it and must not be modified.
+func (s *Scanner) Config() Config {
+	return s.cfg
+}
+
+// Checks returns the checks this scanner will run, in order.
+func (s *Scanner) Checks() []Check {
+	return s.checks
+}
+
+// Printf writes a progress / status message to the configured output.
+func (s *Scanner) Printf(format string, a ...any) {
+	fmt.Fprintf(s.cfg.Output, format, a...)
+}
+
+// RunID returns the ID of the active or last run, recorded in its
+// findings. A resumed Run keeps the ID of the run it continues.
+func (s *Scanner) RunID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runID
+}
+
+// CheckTiming is how long one check of a run took.
+type CheckTiming struct {
+	Check      string
+	Provider   string
+	Candidates int // hostnames / URLs probed (0 for Takeover and Attribute)
+	Duration   time.Duration
+}
+
+// Timings returns how long each check run by this scanner took, in order.
+// Checks completed before a resumed Run are not included.
+func (s *Scanner) Timings() []CheckTiming {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]CheckTiming(nil), s.timings...)
+}
+
+// Emit reports a finding for the check currently running.
+func (s *Scanner) Emit(data OutputData) {
+	s.emitFinding(Finding{OutputData: data})
+}
+
+// emitResolved reports a finding together with the DNS answer behind it.
+func (s *Scanner) emitResolved(data OutputData, ans *DNSAnswer) {
+	a := *ans
+	s.emitFinding(Finding{OutputData: data, Candidate: ans.Name, DNS: &a})
+}
+
+// httpFinding is a finding together with the response behind it.
+func httpFinding(data OutputData, result *HttpResult) Finding {
+	return Finding{OutputData: data, Candidate: result.OriginalURL, HTTP: newHTTPEvidence(result)}
+}
+
+// emitListing reports a finding together with the bucket / container keys
+// listed from url.
+// Throttled listings are retried with backoff.
+func (s *Scanner) emitListing(ctx context.Context, f Finding, url string) {
+	var files []string
+	var err error
+	for attempt := 0; attempt <= maxThrottleRetries; attempt++ {
+		files, err = s.ListBucketContents(ctx, url)
+		var te *throttledError
+		if !errors.As(err, &te) {
+			break
+		}
+		s.stats.throttled.Add(1)
+		if attempt == maxThrottleRetries || sleepCtx(ctx, retryDelay(attempt+1, te.retryAfter)) != nil {
+			break
+		}
+		s.stats.retried.Add(1)
+	}
+	if err != nil {
+		s.Printf("    [!] Could not list %s: %v\n", url, err)
+	}
+	f.Files = files
+	s.emitFinding(f)
+}
+
+// emitFinding stamps a finding with the check, run and time, works out
+// the name behind its candidate, and delivers it. The callback runs
+// without mu held, so it may call back into the Scanner.
+func (s *Scanner) emitFinding(f Finding) {
+	s.mu.Lock()
+	f.Version = FindingVersion
+	f.Check = s.current
+	f.Service = s.service
+	f.RunID = s.runID
+	f.Time = time.Now().UTC()
+	if f.Name = s.nameIn(f.Candidate); f.Name != "" {
+		o := s.cfg.NameOrigins[f.Name]
+		f.Keyword, f.Mutation = o.Keyword, o.Mutation
+	}
+	recorded := s.cp.recordFinding(f)
+	emit := s.emit
+	s.mu.Unlock()
+	if !recorded || emit == nil {
+		return // already reported before the scan was resumed
+	}
+	s.emitMu.Lock()
+	defer s.emitMu.Unlock()
+	emit(f)
+}
+
+// nameIn returns the longest of the Run's names that candidate contains
+// between separators (".", "-", "/", ":" or either end), or "" if none.
+func (s *Scanner) nameIn(candidate string) string {
+	if len(s.names) == 0 {
+		return ""
+	}
+	if _, rest, ok := strings.Cut(candidate, "://"); ok {
+		candidate = rest
+	}
+	isSep := func(c byte) bool { return strings.IndexByte(".-/:", c) >= 0 }
+	best := ""
+	for i := 0; i < len(candidate); i++ {
+		if i > 0 && !isSep(candidate[i-1]) {
+			continue
+		}
+		for j := min(len(candidate), i+63); j > i+len(best); j-- {
+			if (j == len(candidate) || isSep(candidate[j])) && s.names[candidate[i:j]] {
+				best = candidate[i:j]
+				break
+			}
+		}
+	}
+	return best
+}
+
+// Run scans the mutated names with every selected check, calling fn for
+// each finding as soon as it is found. Only one Run may be active per
+// Scanner at a time.
+//
+// When ctx is cancelled no new requests are started, requests already in
+// flight are drained (and their findings reported), and Run returns an
+// error wrapping ctx.Err().
+//
+// With Config.StateFile set, progress is checkpointed periodically. A Run
+// over the same names and config picks up where the previous one stopped,
+// first replaying its findings with Restored set.
+func (s *Scanner) Run(ctx context.Context, names []string, fn func(Finding)) (err error) {
+	cp, err := loadCheckpoint(s.cfg.StateFile, scanHash(names, &s.cfg, s.checks))
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.names = make(map[string]bool, len(names))
+	for _, name := range names {
+		s.names[name] = true
+	}
+	s.runID = cp.state.RunID
+	s.emit = fn
+	s.cp = cp
+	s.mu.Unlock()
+	stopSaving := s.autosave()
+	defer func() {
+		stopSaving()
+		if saveErr := cp.save(); saveErr != nil && err == nil {
+			err = fmt.Errorf("could not write state file: %w", saveErr)
+		}
+		s.mu.Lock()
+		s.emit = nil
+		s.names = nil
+		s.mu.Unlock()
+	}()
+
+	for _, f := range cp.findings() {
+		f.Restored = true
+		fn(f)
+	}
+
+	results := make(map[string][]string)
+	banners := make(map[string]bool)
+
+	for _, c := range s.checks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if found, ok := cp.completed(c.Name()); ok {
+			s.Printf("[*] Skipping %s, completed in a previous run\n", c.Name())
+			results[c.Name()] = found
+			continue
+		}
+		deps := make(map[string][]string)
+		for _, dep := range c.Dependencies() {
+			deps[dep] = results[dep]
+		}
+		candidates := c.Candidates(names, &s.cfg, deps)
+		if len(candidates) == 0 {
+			continue
+		}
+		if !banners[c.Provider()] {
+			banners[c.Provider()] = true
+			s.Printf("%s", providerBanners[c.Provider()])
+		}
+
+		s.mu.Lock()
+		s.current = c.Name()
+		s.provider = c.Provider()
+		s.service = ""
+		if chk, ok := c.(*check); ok {
+			s.service = chk.service
+		}
+		s.mu.Unlock()
+
+		start := time.Now()
+		found, err := c.Run(ctx, s, candidates)
+		s.mu.Lock()
+		s.timings = append(s.timings, CheckTiming{c.Name(), c.Provider(), len(candidates), time.Since(start)})
+		s.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.Name(), err)
+		}
+		results[c.Name()] = found
+		cp.complete(c.Name(), found)
+		if err := cp.save(); err != nil {
+			s.Printf("    [!] Could not write state file: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// session prepares a scan run outside of the checks, such as Takeover:
+// its findings are reported under name and de-duplicated, but nothing is
+// checkpointed. The returned function ends it.
+func (s *Scanner) session(name string, fn func(Finding)) func() {
+	cp, _ := loadCheckpoint("", "")
+	s.mu.Lock()
+	s.current = name
+	s.service = ""
+	s.runID = cp.state.RunID
+	s.emit = fn
+	s.cp = cp
+	s.mu.Unlock()
+	start := time.Now()
+	return func() {
+		s.mu.Lock()
+		s.emit = nil
+		s.timings = append(s.timings, CheckTiming{Check: name, Duration: time.Since(start)})
+		s.mu.Unlock()
+	}
+}
+
+// autosave writes the checkpoint every SaveInterval until the returned
+// function is called.
+func (s *Scanner) autosave() func() {
+	if s.cfg.StateFile == "" {
+		return func() {}
+	}
+	ticker := time.NewTicker(s.cfg.SaveInterval)
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.cp.save(); err != nil {
+					s.Printf("\n    [!] Could not write state file: %v\n", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(stop)
+		<-finished
+	}
+}
+
+// Stream runs the scan in the background. Findings are delivered on the
+// first channel, which is closed when the scan ends; the scan's result is
+// then sent on the second. A consumer that stops reading early must cancel
+// ctx: findings not yet delivered are then dropped and the scan winds down.
+func (s *Scanner) Stream(ctx context.Context, names []string) (<-chan Finding, <-chan error) {
+	findings := make(chan Finding)
+	errc := make(chan error, 1)
+	go func() {
+		err := s.Run(ctx, names, func(f Finding) {
+			select {
+			case findings <- f:
+			case <-ctx.Done():
+			}
+		})
+		close(findings)
+		errc <- err
+	}()
+	return findings, errc
+}
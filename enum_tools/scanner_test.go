@@ -0,0 +1,97 @@
+package enum_tools
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunCallback(t *testing.T) {
+	sc, err := LoadScenario("../scenarios/demo.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim := NewSimulator(sc)
+	if err := sim.Start("127.0.0.1:0", "127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Close()
+	s, err := NewScanner(Config{
+		Checks:      []string{"aws", "gcp-buckets"},
+		Endpoints:   sim.Endpoints(),
+		DNSOverride: sim.DNSAddr(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The callback may call back into the Scanner.
+	done := make(chan error, 1)
+	var findings int
+	go func() {
+		done <- s.Run(context.Background(), BuildNames([]string{"acme"}, []string{"backup"}), func(f Finding) {
+			findings++
+			if runID := s.RunID(); f.RunID != runID {
+				t.Errorf("%s: run %q, want %q", f.Target, f.RunID, runID)
+			}
+			_ = s.Stats()
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("Run deadlocked in the callback")
+	}
+	if findings == 0 {
+		t.Error("no findings")
+	}
+}
+
+func TestClassifyOutsideRun(t *testing.T) {
+	sc, err := LoadScenario("../scenarios/demo.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim := NewSimulator(sc)
+	if err := sim.Start("127.0.0.1:0", "127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Close()
+	_, port, _ := net.SplitHostPort(sim.HTTPAddr())
+	out := &syncBuffer{}
+	s, err := NewScanner(Config{Endpoints: sim.Endpoints(), DNSOverride: sim.DNSAddr(), Output: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		check  string
+		result *HttpResult
+	}{
+		// Listed, which needs a context.
+		{"aws-s3", &HttpResult{URL: "http://acme.s3.amazonaws.com:" + port, StatusCode: 200, Reason: "OK", Header: http.Header{}}},
+		// Remembered, which needs a checkpoint.
+		{"gcp-functions", &HttpResult{URL: "http://us-central1-acme.cloudfunctions.net:" + port, StatusCode: 302, Reason: "Found", Header: http.Header{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.check, func(t *testing.T) {
+			c, ok := LookupCheck(tt.check)
+			if !ok {
+				t.Fatalf("no check %s", tt.check)
+			}
+			tt.result.OriginalURL = tt.result.URL
+			if c.Classify(s, tt.result) {
+				t.Error("broke out")
+			}
+		})
+	}
+	if strings.Contains(out.String(), "Could not list") {
+		t.Errorf("listing failed:\n%s", out)
+	}
+}
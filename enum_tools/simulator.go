@@ -0,0 +1,643 @@
+package enum_tools
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Scenarios
+// ---------------------------------------------------------------------------
+
+// Scenario describes the fake cloud served by a Simulator.
+type Scenario struct {
+	Address   string            `json:"address"`    // IP simulated names resolve to (default 127.0.0.1)
+	Resources []SimResource     `json:"resources"`  // HTTP resources; their hosts resolve too
+	DNS       []string          `json:"dns"`        // extra names that only need to resolve; "*.zone" for wildcards
+	CNAMEs    map[string]string `json:"cnames"`     // name -> CNAME target
+	DNSErrors map[string]string `json:"dns_errors"` // name -> rcode (SERVFAIL, REFUSED, ...)
+	Throttle  *SimThrottle      `json:"throttle"`   // throttle requests above a rate
+	LatencyMS int               `json:"latency_ms"` // delay before every HTTP response
+}
+
+// SimThrottle answers requests above RPS with a throttling preset, to
+// exercise the scanner's backoff.
+type SimThrottle struct {
+	RPS        float64 `json:"rps"`
+	Burst      int     `json:"burst"`
+	Preset     string  `json:"preset"`      // default "s3-slowdown"
+	RetryAfter int     `json:"retry_after"` // seconds, sent as Retry-After when set
+}
+
+// SimResource is one simulated HTTP resource. Either Preset or Status must
+// be set; explicit fields override the preset's.
+type SimResource struct {
+	URL     string            `json:"url"`    // host[/path] the response is served for
+	Preset  string            `json:"preset"` // canned response, see SimPresets
+	Status  int               `json:"status"`
+	Reason  string            `json:"reason"` // reason phrase (the classifiers match on it)
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// simResponse is a canned HTTP response. "{login}" in headers or body is
+// replaced with the simulated Google login page.
+type simResponse struct {
+	status  int
+	reason  string
+	headers map[string]string
+	body    string
+}
+
+// simPreset is a preset's response for the resource itself and for paths
+// below it (nil child means the same response everywhere).
+type simPreset struct {
+	root  simResponse
+	child *simResponse
+}
+
+const (
+	s3ListBody = `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>bucket</Name><Contents><Key>backup.sql</Key></Contents><Contents><Key>index.html</Key></Contents></ListBucketResult>`
+	azureListBody = `<?xml version="1.0" encoding="utf-8"?>
+<EnumerationResults><Blobs><Blob><Name>report.pdf</Name></Blob><Blob><Name>users.csv</Name></Blob></Blobs></EnumerationResults>`
+
+	azureAuthReason     = "Server failed to authenticate the request. Make sure the value of Authorization header is formed correctly including the signature."
+	azureDisabledReason = "The specified account is disabled."
+	azureQueryReason    = "Value for one of the query parameters specified in the request URI is invalid."
+)
+
+var (
+	xmlHeaders = map[string]string{"Content-Type": "application/xml"}
+	notFound   = &simResponse{status: 404, reason: "Not Found"}
+	loginRedir = simResponse{status: 302, reason: "Found", headers: map[string]string{"Location": "{login}"}}
+)
+
+// azureError builds an Azure storage error whose message is both the
+// reason phrase and the body, like the real service.
+func azureError(status int, code, msg string) simResponse {
+	return simResponse{
+		status:  status,
+		reason:  msg,
+		headers: xmlHeaders,
+		body:    `<?xml version="1.0" encoding="utf-8"?><Error><Code>` + code + `</Code><Message>` + msg + `</Message></Error>`,
+	}
+}
+
+// s3Error builds an S3 error document.
+func s3Error(status int, reason, code string) simResponse {
+	return simResponse{
+		status:  status,
+		reason:  reason,
+		headers: xmlHeaders,
+		body:    `<?xml version="1.0" encoding="UTF-8"?><Error><Code>` + code + `</Code></Error>`,
+	}
+}
+
+func respPtr(r simResponse) *simResponse { return &r }
+
+// simPresets holds the canned responses the classifiers know about.
+var simPresets = map[string]simPreset{
+	"s3-open":      {root: simResponse{status: 200, reason: "OK", headers: xmlHeaders, body: s3ListBody}, child: notFound},
+	"s3-protected": {root: s3Error(403, "Forbidden", "AccessDenied")},
+	"s3-missing":   {root: s3Error(404, "Not Found", "NoSuchBucket")},
+	"s3-slowdown":  {root: s3Error(503, "Slow Down", "SlowDown")},
+
+	"azure-auth":       {root: azureError(403, "AuthenticationFailed", azureAuthReason)},
+	"azure-disabled":   {root: azureError(409, "AccountIsDisabled", azureDisabledReason)},
+	"azure-http-ok":    {root: azureError(400, "InvalidQueryParameterValue", azureQueryReason), child: respPtr(azureError(404, "ContainerNotFound", "The specified container does not exist."))},
+	"azure-https-only": {root: azureError(400, "AccountRequiresHttps", "The account being accessed does not support http.")},
+	"azure-no-public": {
+		root:  azureError(400, "InvalidQueryParameterValue", azureQueryReason),
+		child: respPtr(azureError(409, "PublicAccessNotPermitted", "Public access is not permitted on this storage account.")),
+	},
+	"azure-busy":           {root: azureError(503, "ServerBusy", "The server is busy.")},
+	"azure-container-open": {root: simResponse{status: 200, reason: "OK", headers: xmlHeaders, body: azureListBody}, child: notFound},
+
+	"gcs-open":      {root: simResponse{status: 200, reason: "OK", headers: xmlHeaders, body: s3ListBody}, child: notFound},
+	"gcs-protected": {root: s3Error(403, "Forbidden", "AccessDenied")},
+	"gcs-throttled": {root: simResponse{status: 429, reason: "Too Many Requests", body: `{"error": {"errors": [{"reason": "rateLimitExceeded"}]}}`}},
+
+	"firebase-open":        {root: simResponse{status: 200, reason: "OK", body: "{}"}},
+	"firebase-protected":   {root: simResponse{status: 401, reason: "Unauthorized", body: `{"error": "Permission denied"}`}},
+	"firebase-payment":     {root: simResponse{status: 402, reason: "Payment Required", body: `{"error": "Payment required"}`}},
+	"firebase-deactivated": {root: simResponse{status: 423, reason: "Locked", body: `{"error": "The Firebase database has been deactivated."}`}},
+	"fbapp-open":           {root: simResponse{status: 200, reason: "OK", body: "<html>Firebase Hosting</html>"}},
+
+	"appspot-open":      {root: simResponse{status: 200, reason: "OK", body: "<html>Hello, App Engine</html>"}},
+	"appspot-protected": {root: loginRedir},
+	"appspot-error":     {root: simResponse{status: 500, reason: "Internal Server Error"}},
+
+	"functions-project":   {root: loginRedir},
+	"functions-open":      {root: simResponse{status: 200, reason: "OK", body: "Hello World!"}, child: notFound},
+	"functions-auth":      {root: simResponse{status: 403, reason: "Forbidden"}, child: notFound},
+	"functions-post-only": {root: simResponse{status: 405, reason: "Method Not Allowed"}, child: notFound},
+}
+
+// loginHost is the simulated Google login page that protected apps and
+// Cloud Functions projects redirect to.
+const loginHost = "accounts.google.com"
+
+// SimPresets lists the preset names usable in a scenario.
+func SimPresets() []string {
+	var out []string
+	for name := range simPresets {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ParseScenario parses and validates a JSON scenario.
+func ParseScenario(data []byte) (*Scenario, error) {
+	var sc Scenario
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("invalid scenario: %w", err)
+	}
+	if sc.Address == "" {
+		sc.Address = "127.0.0.1"
+	}
+	if net.ParseIP(sc.Address).To4() == nil {
+		return nil, fmt.Errorf("scenario address %q is not an IPv4 address", sc.Address)
+	}
+	if t := sc.Throttle; t != nil {
+		if t.RPS <= 0 {
+			return nil, errors.New("scenario throttle needs a positive rps")
+		}
+		if t.Preset == "" {
+			t.Preset = "s3-slowdown"
+		}
+		if _, ok := simPresets[t.Preset]; !ok {
+			return nil, fmt.Errorf("scenario throttle: unknown preset %q", t.Preset)
+		}
+	}
+	for i, r := range sc.Resources {
+		if r.URL == "" {
+			return nil, fmt.Errorf("scenario resource %d has no url", i)
+		}
+		if r.Preset == "" && r.Status == 0 {
+			return nil, fmt.Errorf("scenario resource %s needs a preset or a status", r.URL)
+		}
+		if _, ok := simPresets[r.Preset]; r.Preset != "" && !ok {
+			return nil, fmt.Errorf("scenario resource %s: unknown preset %q (known: %s)", r.URL, r.Preset, strings.Join(SimPresets(), ", "))
+		}
+	}
+	for name, rcode := range sc.DNSErrors {
+		if _, ok := RcodeByName(rcode); !ok {
+			return nil, fmt.Errorf("scenario dns error for %s: unknown rcode %q", name, rcode)
+		}
+	}
+	return &sc, nil
+}
+
+// LoadScenario reads a JSON scenario file.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read scenario: %w", err)
+	}
+	return ParseScenario(data)
+}
+
+// ---------------------------------------------------------------------------
+// Simulator
+// ---------------------------------------------------------------------------
+
+// simRoute is a resource bound to a host and path.
+type simRoute struct {
+	path   string // without trailing slash, "" for the host itself
+	preset simPreset
+}
+
+// Simulator serves a scenario over HTTP and DNS so the checks can be run
+// against it with Endpoints() and a DNS override instead of real clouds.
+type Simulator struct {
+	addr      net.IP
+	routes    map[string][]simRoute // by lower-case host
+	names     map[string]bool       // names that resolve
+	wildcards []string              // zones where every name resolves, as ".zone"
+	cnames    map[string]string     // name -> CNAME target
+	rcodes    map[string]int        // names answered with an error
+	zones     map[string]bool       // zones it claims to be authoritative for
+
+	throttle     *tokenBucket // nil unless the scenario throttles
+	throttleResp simResponse
+	latency      time.Duration
+
+	// Log receives one line per request when set.
+	Log io.Writer
+
+	httpLn  net.Listener
+	dnsConn net.PacketConn
+	dnsLn   net.Listener // DNS over TCP
+	wg      sync.WaitGroup
+}
+
+// NewSimulator prepares a Simulator for a scenario.
+func NewSimulator(sc *Scenario) *Simulator {
+	sim := &Simulator{
+		addr:    net.ParseIP(sc.Address).To4(),
+		routes:  make(map[string][]simRoute),
+		cnames:  make(map[string]string),
+		rcodes:  make(map[string]int),
+		zones:   make(map[string]bool),
+		names:   map[string]bool{loginHost: true},
+		latency: time.Duration(sc.LatencyMS) * time.Millisecond,
+	}
+	for _, r := range sc.Resources {
+		host, path, _ := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(r.URL, "http://"), "https://"), "/")
+		host = strings.ToLower(host)
+
+		p := simPresets[r.Preset]
+		if r.Status != 0 {
+			p.root.status = r.Status
+			if p.root.reason = r.Reason; p.root.reason == "" {
+				p.root.reason = http.StatusText(r.Status)
+			}
+		} else if r.Reason != "" {
+			p.root.reason = r.Reason
+		}
+		if r.Headers != nil {
+			p.root.headers = r.Headers
+		}
+		if r.Body != "" {
+			p.root.body = r.Body
+		}
+		if r.Preset == "" {
+			p.child = notFound
+		}
+
+		sim.routes[host] = append(sim.routes[host], simRoute{path: "/" + strings.Trim(path, "/"), preset: p})
+		sim.names[host] = true
+	}
+	if t := sc.Throttle; t != nil {
+		sim.throttle = newTokenBucket(RateLimit{RPS: t.RPS, Burst: t.Burst})
+		sim.throttleResp = simPresets[t.Preset].root
+		if t.RetryAfter > 0 {
+			sim.throttleResp.headers = map[string]string{"Retry-After": strconv.Itoa(t.RetryAfter)}
+		}
+	}
+	for _, name := range sc.DNS {
+		name = simName(name)
+		if zone, ok := strings.CutPrefix(name, "*."); ok {
+			sim.wildcards = append(sim.wildcards, "."+zone)
+		} else {
+			sim.names[name] = true
+		}
+	}
+	for name, target := range sc.CNAMEs {
+		sim.cnames[simName(name)] = simName(target)
+	}
+	for name, rcode := range sc.DNSErrors {
+		sim.rcodes[simName(name)], _ = RcodeByName(rcode)
+	}
+	// The simulator is also the authoritative server of every service zone.
+	sim.names[simNameserver] = true
+	for _, e := range DefaultEndpoints {
+		if i := strings.LastIndex(e.Host, "}"); i >= 0 {
+			sim.zones[strings.TrimPrefix(e.Host[i+1:], ".")] = true
+		}
+	}
+	for _, zone := range sim.wildcards {
+		sim.zones[strings.TrimPrefix(zone, ".")] = true
+	}
+	// Like the real clouds, the zones of services only probed over HTTP
+	// resolve for any name; missing resources are told apart by status.
+	for service, e := range DefaultEndpoints {
+		if dnsServices[service] {
+			continue
+		}
+		zone := e.Host
+		if i := strings.LastIndex(zone, "}"); i >= 0 {
+			zone = zone[i+1:]
+		} else {
+			sim.names[zone] = true // path-style host
+			continue
+		}
+		sim.wildcards = append(sim.wildcards, zone)
+	}
+	return sim
+}
+
+// Start listens on the given HTTP address and on the DNS address over both
+// UDP and TCP; port 0 picks a free port.
+func (sim *Simulator) Start(httpAddr, dnsAddr string) error {
+	ln, err := net.Listen("tcp", httpAddr)
+	if err != nil {
+		return fmt.Errorf("simulator http: %w", err)
+	}
+	pc, err := net.ListenPacket("udp", dnsAddr)
+	if err != nil {
+		ln.Close()
+		return fmt.Errorf("simulator dns: %w", err)
+	}
+	// TCP DNS shares the UDP port, which may have been picked just now.
+	tcpLn, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		ln.Close()
+		pc.Close()
+		return fmt.Errorf("simulator dns: %w", err)
+	}
+	sim.httpLn, sim.dnsConn, sim.dnsLn = ln, pc, tcpLn
+
+	sim.wg.Add(3)
+	go sim.serveHTTP()
+	go sim.serveDNS()
+	go sim.serveDNSTCP()
+	return nil
+}
+
+// Close stops the servers.
+func (sim *Simulator) Close() error {
+	err := sim.httpLn.Close()
+	if dnsErr := sim.dnsConn.Close(); err == nil {
+		err = dnsErr
+	}
+	if dnsErr := sim.dnsLn.Close(); err == nil {
+		err = dnsErr
+	}
+	sim.wg.Wait()
+	return err
+}
+
+// HTTPAddr and DNSAddr return the addresses the servers listen on.
+func (sim *Simulator) HTTPAddr() string { return sim.httpLn.Addr().String() }
+func (sim *Simulator) DNSAddr() string  { return sim.dnsConn.LocalAddr().String() }
+
+// Endpoints returns endpoint overrides sending every service to the
+// simulator. Hostnames are kept, so Config.DNSOverride must point at
+// DNSAddr().
+func (sim *Simulator) Endpoints() map[string]Endpoint {
+	_, port, _ := net.SplitHostPort(sim.HTTPAddr())
+	p, _ := strconv.Atoi(port)
+	out := make(map[string]Endpoint)
+	for service, e := range DefaultEndpoints {
+		e.Scheme = "http"
+		e.Port = p
+		out[service] = e
+	}
+	return out
+}
+
+func (sim *Simulator) logf(format string, a ...any) {
+	if sim.Log != nil {
+		fmt.Fprintf(sim.Log, format, a...)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// HTTP
+// ---------------------------------------------------------------------------
+
+// serveHTTP runs a minimal HTTP/1.1 server. net/http's server can't send
+// custom reason phrases, which the Azure classifiers rely on.
+func (sim *Simulator) serveHTTP() {
+	defer sim.wg.Done()
+	for {
+		conn, err := sim.httpLn.Accept()
+		if err != nil {
+			return
+		}
+		go sim.handleConn(conn)
+	}
+}
+
+func (sim *Simulator) handleConn(conn net.Conn) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		body, _ := io.ReadAll(io.LimitReader(req.Body, 65535))
+		req.Body.Close()
+
+		if req.URL.Path == "/dns-query" {
+			if err := sim.serveDoH(conn, req, body); err != nil || req.Close {
+				return
+			}
+			continue
+		}
+		resp := sim.lookup(req.Host, req.URL.Path)
+		if sim.throttle != nil && !sim.throttle.take() {
+			resp = sim.throttleResp
+		}
+		time.Sleep(sim.latency)
+		sim.logf("[sim] %s %s%s -> %d %s\n", req.Method, req.Host, req.URL.RequestURI(), resp.status, resp.reason)
+		if err := sim.writeResponse(conn, resp); err != nil || req.Close {
+			return
+		}
+	}
+}
+
+// lookup finds the response for a request: an exact resource match, else
+// the child response of the closest resource above the path, else 404.
+func (sim *Simulator) lookup(hostport, path string) simResponse {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if host == loginHost {
+		return simResponse{status: 200, reason: "OK", body: "<html>Sign in - Google Accounts</html>"}
+	}
+
+	path = "/" + strings.Trim(path, "/")
+	var best *simRoute
+	for i, r := range sim.routes[host] {
+		if r.path == path {
+			return r.preset.root
+		}
+		if r.path == "/" || strings.HasPrefix(path, r.path+"/") {
+			if best == nil || len(r.path) > len(best.path) {
+				best = &sim.routes[host][i]
+			}
+		}
+	}
+	if best == nil {
+		return *notFound
+	}
+	if best.preset.child != nil {
+		return *best.preset.child
+	}
+	return best.preset.root
+}
+
+func (sim *Simulator) writeResponse(w io.Writer, resp simResponse) error {
+	login := "http://" + loginHost
+	if _, port, err := net.SplitHostPort(sim.HTTPAddr()); err == nil {
+		login += ":" + port
+	}
+	login += "/ServiceLogin"
+	expand := strings.NewReplacer("{login}", login)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "HTTP/1.1 %d %s\r\n", resp.status, resp.reason)
+	for k, v := range resp.headers {
+		fmt.Fprintf(bw, "%s: %s\r\n", k, expand.Replace(v))
+	}
+	body := expand.Replace(resp.body)
+	fmt.Fprintf(bw, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return bw.Flush()
+}
+
+// ---------------------------------------------------------------------------
+// DNS
+// ---------------------------------------------------------------------------
+
+// serveDNS answers A queries for the scenario's names with its address,
+// following its CNAMEs, other types with an empty answer and unknown names
+// with NXDOMAIN.
+func (sim *Simulator) serveDNS() {
+	defer sim.wg.Done()
+	buf := make([]byte, 512)
+	for {
+		n, from, err := sim.dnsConn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		if reply := sim.answer(buf[:n]); reply != nil {
+			sim.dnsConn.WriteTo(reply, from)
+		}
+	}
+}
+
+// serveDNSTCP answers length-prefixed queries over TCP, e.g. those sent
+// through a SOCKS proxy.
+func (sim *Simulator) serveDNSTCP() {
+	defer sim.wg.Done()
+	for {
+		conn, err := sim.dnsLn.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			for {
+				var size [2]byte
+				if _, err := io.ReadFull(conn, size[:]); err != nil {
+					return
+				}
+				query := make([]byte, binary.BigEndian.Uint16(size[:]))
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+				reply := sim.answer(query)
+				if reply == nil {
+					return
+				}
+				if _, err := conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(reply))), reply...)); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// serveDoH answers a DNS-over-HTTPS request (RFC 8484, POST or GET), on
+// any host, so the HTTP address doubles as a DoH stand-in.
+func (sim *Simulator) serveDoH(w io.Writer, req *http.Request, body []byte) error {
+	query := body
+	if req.Method == http.MethodGet {
+		query, _ = base64.RawURLEncoding.DecodeString(req.URL.Query().Get("dns"))
+	}
+	reply := sim.answer(query)
+	if reply == nil {
+		return sim.writeResponse(w, simResponse{status: 400, reason: "Bad Request"})
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "HTTP/1.1 200 OK\r\nContent-Type: application/dns-message\r\nContent-Length: %d\r\n\r\n", len(reply))
+	bw.Write(reply)
+	return bw.Flush()
+}
+
+// resolves reports whether name exists in the simulated DNS.
+func (sim *Simulator) resolves(name string) bool {
+	if sim.names[name] {
+		return true
+	}
+	for _, zone := range sim.wildcards {
+		if strings.HasSuffix(name, zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// simNameserver is the NS record of the simulated zones.
+const simNameserver = "ns1.simulator.test"
+
+// simName normalises a scenario DNS name.
+func simName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// answer builds the reply to a single-question DNS query.
+func (sim *Simulator) answer(query []byte) []byte {
+	q, err := parseMsg(query)
+	if err != nil || q.Response || q.QName == "" {
+		return nil
+	}
+
+	// Follow the CNAME chain, like a recursive resolver would.
+	var answers []dnsRR
+	name := q.QName
+	for len(answers) < maxCNAMEs {
+		target, ok := sim.cnames[name]
+		if !ok || q.QType == dnsTypeCNAME {
+			break
+		}
+		answers = append(answers, dnsRR{Name: name, Type: dnsTypeCNAME, TTL: 60, Target: target})
+		name = target
+	}
+	rcode, failed := sim.rcodes[name]
+	switch {
+	case failed:
+		answers = nil
+	case sim.cnames[name] != "" && q.QType == dnsTypeCNAME:
+		answers = append(answers, dnsRR{Name: name, Type: dnsTypeCNAME, TTL: 60, Target: sim.cnames[name]})
+	case q.QType == dnsTypeNS && sim.zones[name]:
+		answers = append(answers, dnsRR{Name: name, Type: dnsTypeNS, TTL: 3600, Target: simNameserver})
+	case !sim.resolves(name):
+		rcode = rcodeNXDomain
+	case q.QType == dnsTypeA:
+		answers = append(answers, dnsRR{Name: name, Type: dnsTypeA, TTL: 60, Data: sim.addr})
+	}
+
+	reply := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(reply, q.ID)
+	flags := uint16(0x8080) | uint16(rcode) // QR, RA
+	if q.RD {
+		flags |= 0x0100
+	}
+	binary.BigEndian.PutUint16(reply[2:], flags)
+	binary.BigEndian.PutUint16(reply[4:], 1)
+	binary.BigEndian.PutUint16(reply[6:], uint16(len(answers)))
+	reply, _ = appendWireName(reply, q.QName)
+	reply = binary.BigEndian.AppendUint16(reply, q.QType)
+	reply = binary.BigEndian.AppendUint16(reply, dnsClassIN)
+	for _, rr := range answers {
+		if reply, err = appendRR(reply, rr); err != nil {
+			return nil
+		}
+	}
+	sim.logf("[sim] DNS %s type %d -> %d answer(s), %s\n", q.QName, q.QType, len(answers), RcodeName(rcode))
+	return reply
+}
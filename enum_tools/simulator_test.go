@@ -0,0 +1,197 @@
+package enum_tools
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// syncBuffer collects a scanner's messages, which are printed from many
+// goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// simScan runs the checks against a simulator serving sc and returns the
+// findings, the scanner's messages and the simulator's HTTP port.
+func simScan(t *testing.T, sc *Scenario, checks, mutations []string, brute string) ([]Finding, string, string) {
+	t.Helper()
+	sim := NewSimulator(sc)
+	if err := sim.Start("127.0.0.1:0", "127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Close()
+	_, port, _ := net.SplitHostPort(sim.HTTPAddr())
+
+	out := &syncBuffer{}
+	s, err := NewScanner(Config{
+		Checks:      checks,
+		BruteData:   brute,
+		Endpoints:   sim.Endpoints(),
+		DNSOverride: sim.DNSAddr(),
+		Output:      out,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var findings []Finding
+	names := BuildNames([]string{"acme"}, mutations)
+	err = s.Run(context.Background(), names, func(f Finding) {
+		mu.Lock()
+		findings = append(findings, f)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return findings, out.String(), port
+}
+
+func TestScanDemoScenario(t *testing.T) {
+	sc, err := LoadScenario("../scenarios/demo.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings, _, port := simScan(t, sc, nil, []string{"backup", "dev", "prod"}, "api\nadmin\nbackup\n")
+
+	tests := []struct {
+		check, access, target string
+		files                 int // listed keys, -1 when not listed
+	}{
+		{"aws-s3", "public", "http://acme.s3.amazonaws.com:{port}", 3},
+		{"aws-s3", "protected", "http://acme-backup.s3.amazonaws.com:{port}", -1},
+		{"aws-apps", "protected", "https://acme.awsapps.com", -1},
+		{"azure-blob", "public", "http://acme.blob.core.windows.net:{port}", -1},
+		{"azure-blob", "protected", "http://acmeprod.blob.core.windows.net:{port}", -1},
+		{"azure-blob", "disabled", "http://acmedev.blob.core.windows.net:{port}", -1},
+		{"azure-file", "public", "http://acme.file.core.windows.net:{port}", -1},
+		{"azure-containers", "public", "http://acme.blob.core.windows.net:{port}/backup/?restype=container&comp=list", 2},
+		{"azure-websites", "public", "acme.azurewebsites.net", -1},
+		{"azure-databases", "public", "acme.database.windows.net", -1},
+		{"gcp-buckets", "public", "http://storage.googleapis.com:{port}/acme", 3},
+		{"gcp-buckets", "protected", "http://storage.googleapis.com:{port}/acme-prod", -1},
+		{"gcp-firebase-rtdb", "protected", "http://acme.firebaseio.com:{port}/.json", -1},
+		{"gcp-firebase-rtdb", "disabled", "http://acmedev.firebaseio.com:{port}/.json", -1},
+		{"gcp-firebase-rtdb", "disabled", "http://acmeprod.firebaseio.com:{port}/.json", -1},
+		{"gcp-appspot", "public", "http://acme.appspot.com:{port}", -1},
+		{"gcp-appspot", "protected", "http://acmeprod.appspot.com:{port}", -1},
+		{"gcp-functions", "public", "http://us-central1-acme.cloudfunctions.net:{port}", -1},
+		{"gcp-functions", "public", "http://us-central1-acme.cloudfunctions.net:{port}/api/", -1},
+		{"gcp-functions", "protected", "http://us-central1-acme.cloudfunctions.net:{port}/admin/", -1},
+	}
+
+	got := make(map[string]Finding)
+	for _, f := range findings {
+		got[f.Check+" "+f.Target] = f
+	}
+	for _, tt := range tests {
+		target := strings.ReplaceAll(tt.target, "{port}", port)
+		t.Run(tt.check+" "+target, func(t *testing.T) {
+			f, ok := got[tt.check+" "+target]
+			if !ok {
+				t.Fatal("not found")
+			}
+			delete(got, tt.check+" "+target)
+			if f.Access != tt.access {
+				t.Errorf("access %q, want %q", f.Access, tt.access)
+			}
+			if files := len(f.Files); f.Files == nil && tt.files >= 0 || f.Files != nil && files != tt.files {
+				t.Errorf("files %v, want %d", f.Files, tt.files)
+			}
+		})
+	}
+	var extra []string
+	for key := range got {
+		extra = append(extra, key)
+	}
+	sort.Strings(extra)
+	for _, key := range extra {
+		t.Errorf("unexpected finding %s", key)
+	}
+}
+
+func TestParseScenario(t *testing.T) {
+	tests := []struct {
+		name, json string
+		fail       bool
+	}{
+		{"empty", `{}`, false},
+		{"preset", `{"resources": [{"url": "acme.s3.amazonaws.com", "preset": "s3-open"}]}`, false},
+		{"status", `{"resources": [{"url": "acme.s3.amazonaws.com", "status": 403}]}`, false},
+		{"throttle", `{"throttle": {"rps": 5}}`, false},
+		{"invalid json", `{"resources": [}`, true},
+		{"ipv6 address", `{"address": "::1"}`, true},
+		{"resource without url", `{"resources": [{"preset": "s3-open"}]}`, true},
+		{"resource without response", `{"resources": [{"url": "acme.s3.amazonaws.com"}]}`, true},
+		{"unknown preset", `{"resources": [{"url": "acme.s3.amazonaws.com", "preset": "s3-gone"}]}`, true},
+		{"throttle without rate", `{"throttle": {"burst": 5}}`, true},
+		{"unknown throttle preset", `{"throttle": {"rps": 5, "preset": "nope"}}`, true},
+		{"unknown rcode", `{"dns_errors": {"acme.database.windows.net": "BROKEN"}}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseScenario([]byte(tt.json))
+			if tt.fail {
+				if err == nil {
+					t.Fatal("no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sc.Address != "127.0.0.1" {
+				t.Errorf("default address %q", sc.Address)
+			}
+		})
+	}
+}
+
+func TestSimulatorLookup(t *testing.T) {
+	sim := NewSimulator(&Scenario{
+		Address: "127.0.0.1",
+		Resources: []SimResource{
+			{URL: "acme.blob.core.windows.net/backup", Preset: "azure-container-open"},
+			{URL: "ACME.s3.amazonaws.com", Status: 403},
+			{URL: "acme.appspot.com", Status: 200, Reason: "Fine", Body: "hello"},
+		},
+	})
+	tests := []struct {
+		host, path string
+		status     int
+		reason     string
+	}{
+		{"acme.s3.amazonaws.com:8080", "/", 403, "Forbidden"},
+		{"acme.s3.amazonaws.com", "/key", 404, "Not Found"}, // explicit status: nothing below
+		{"acme.appspot.com", "", 200, "Fine"},
+		{"acme.blob.core.windows.net", "/backup", 200, "OK"},
+		{"acme.blob.core.windows.net", "/backup/", 200, "OK"},
+		{"acme.blob.core.windows.net", "/backup/report.pdf", 404, "Not Found"},
+		{"acme.blob.core.windows.net", "/other", 404, "Not Found"},
+		{"other.s3.amazonaws.com", "/", 404, "Not Found"},
+	}
+	for _, tt := range tests {
+		resp := sim.lookup(tt.host, tt.path)
+		if resp.status != tt.status || resp.reason != tt.reason {
+			t.Errorf("%s%s: %d %s, want %d %s", tt.host, tt.path, resp.status, resp.reason, tt.status, tt.reason)
+		}
+	}
+}
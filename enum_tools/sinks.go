@@ -0,0 +1,193 @@
+package enum_tools
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Output sinks
+// ---------------------------------------------------------------------------
+
+// Sink receives the findings of a scan and writes them out in one format.
+// Output is buffered: Flush pushes it out, Close flushes and releases the
+// sink. Sinks are safe for concurrent use.
+type Sink interface {
+	Write(f Finding) error
+	Flush() error
+	Close() error
+}
+
+// SinkFormats lists the formats a sink can write: text ("msg: target"
+// and a line of details), json (one object per line) and csv (logColumns).
+var SinkFormats = []string{"text", "json", "csv"}
+
+// formatSink writes findings in one format through a buffered writer.
+type formatSink struct {
+	mu        sync.Mutex
+	format    string
+	w         *bufio.Writer
+	csv       *csv.Writer
+	closer    io.Closer // nil when the sink doesn't own the writer
+	autoFlush bool      // flush after every finding
+}
+
+// NewSink returns a sink writing findings in format to w. Each finding is
+// flushed as soon as it is written, for streams read while the scan runs
+// such as stdout. Closing the sink doesn't close w.
+func NewSink(format string, w io.Writer) (Sink, error) {
+	if err := checkSinkFormat(format); err != nil {
+		return nil, err
+	}
+	s := &formatSink{format: format, w: bufio.NewWriter(w), autoFlush: true}
+	if format == "csv" {
+		s.csv = csv.NewWriter(s.w)
+		_ = s.csv.Write(logColumns)
+	}
+	return s, s.Flush()
+}
+
+// OpenSink opens the file at path, in append mode, as a sink writing
+// format. Text files get a run header, and new csv files a header row;
+// existing csv files must have the same columns.
+func OpenSink(format, path string) (Sink, error) {
+	if err := checkSinkFormat(format); err != nil {
+		return nil, err
+	}
+	if format == "csv" {
+		if err := checkCSVColumns(path); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	s := &formatSink{format: format, w: bufio.NewWriter(f), closer: f}
+	switch format {
+	case "text":
+		fmt.Fprintf(s.w, "\n\n#### CLOUD_ENUM %s ####\n", time.Now().Format("02/01/2006 15:04:05"))
+	case "csv":
+		s.csv = csv.NewWriter(s.w)
+		if info.Size() == 0 {
+			_ = s.csv.Write(logColumns)
+		}
+	}
+	return s, nil
+}
+
+// checkCSVColumns fails when the csv file at path already has rows but its
+// header isn't logColumns: appended rows wouldn't match it.
+func checkCSVColumns(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not open log file: %w", err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil || !slices.Equal(header, logColumns) {
+		return fmt.Errorf("%s has different csv columns than this version writes; use a new file", path)
+	}
+	return nil
+}
+
+// ParseSinkSpec splits an output spec "format:path" ("-" for stdout). A
+// bare "-" is json on stdout.
+func ParseSinkSpec(spec string) (format, path string, err error) {
+	if spec == "-" {
+		return "json", "-", nil
+	}
+	format, path, ok := strings.Cut(spec, ":")
+	if !ok || path == "" {
+		return "", "", fmt.Errorf("output %q is not format:path", spec)
+	}
+	if format == "jsonl" {
+		format = "json"
+	}
+	return format, path, checkSinkFormat(format)
+}
+
+func checkSinkFormat(format string) error {
+	for _, f := range SinkFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported output format %q (allowed: %s)", format, strings.Join(SinkFormats, ", "))
+}
+
+func (s *formatSink) Write(f Finding) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	switch s.format {
+	case "text":
+		var details []string
+		row := logRow(f)
+		for i, col := range logColumns {
+			if row[i] == "" || col == "msg" || col == "target" {
+				continue
+			}
+			if strings.ContainsAny(row[i], " \"") {
+				row[i] = strconv.Quote(row[i])
+			}
+			details = append(details, col+"="+row[i])
+		}
+		_, err = fmt.Fprintf(s.w, "%s: %s\n    %s\n", f.Msg, f.Target, strings.Join(details, " "))
+	case "csv":
+		if err = s.csv.Write(logRow(f)); err == nil {
+			s.csv.Flush()
+			err = s.csv.Error()
+		}
+	case "json":
+		err = json.NewEncoder(s.w).Encode(f)
+	}
+	if err == nil && s.autoFlush {
+		err = s.w.Flush()
+	}
+	return err
+}
+
+func (s *formatSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.csv != nil {
+		s.csv.Flush()
+	}
+	return s.w.Flush()
+}
+
+func (s *formatSink) Close() error {
+	err := s.Flush()
+	if s.closer != nil {
+		if closeErr := s.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,205 @@
+package enum_tools
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testFinding is a public bucket found over HTTP, with a listing.
+var testFinding = Finding{
+	Version:    FindingVersion,
+	OutputData: OutputData{Platform: "aws", Msg: "OPEN S3 BUCKET", Target: "http://acme.s3.amazonaws.com", Access: "public", Severity: "high"},
+	Check:      "aws-s3",
+	Service:    "s3",
+	Name:       "acme",
+	Keyword:    "acme",
+	Candidate:  "acme.s3.amazonaws.com",
+	HTTP:       &HTTPEvidence{URL: "http://acme.s3.amazonaws.com", Status: 200, Reason: "200 OK", Headers: map[string]string{"Server": "AmazonS3"}},
+	Files:      []string{"a.txt", "b.txt"},
+	Time:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	RunID:      "run1",
+}
+
+func TestNewSink(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"text", "OPEN S3 BUCKET: http://acme.s3.amazonaws.com\n" +
+			`    version=2 time=2024-05-01T12:00:00Z run_id=run1 check=aws-s3 service=s3 platform=aws access=public severity=high ` +
+			`name=acme keyword=acme candidate=acme.s3.amazonaws.com http_status=200 http_reason="200 OK" http_headers="Server: AmazonS3" files=2` + "\n"},
+		{"csv", strings.Join(logColumns, ",") + "\n" +
+			"2,2024-05-01T12:00:00Z,run1,aws-s3,s3,aws,OPEN S3 BUCKET,http://acme.s3.amazonaws.com,public,high,acme,acme,,acme.s3.amazonaws.com,200,200 OK,Server: AmazonS3,,,,2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			s, err := NewSink(tt.format, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Findings reach the writer without an explicit flush.
+			if err := s.Write(testFinding); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		s, err := NewSink("json", &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := s.Write(testFinding); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if n := strings.Count(buf.String(), "\n"); n != 2 {
+			t.Errorf("%d lines, want one per finding", n)
+		}
+		findings, err := ReadJSONLog(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(findings) != 2 || findings[0].Target != testFinding.Target || !slices.Equal(findings[0].Files, testFinding.Files) ||
+			findings[0].HTTP.Headers["Server"] != "AmazonS3" || !findings[0].Time.Equal(testFinding.Time) {
+			t.Errorf("read back %+v", findings)
+		}
+	})
+
+	if _, err := NewSink("xml", &bytes.Buffer{}); err == nil {
+		t.Error("xml: no error")
+	}
+}
+
+func TestOpenSink(t *testing.T) {
+	for _, format := range SinkFormats {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "log."+format)
+			// Two runs append to the same file.
+			for run := 0; run < 2; run++ {
+				s, err := OpenSink(format, path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := s.Write(testFinding); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			switch format {
+			case "text":
+				if n := strings.Count(string(data), "#### CLOUD_ENUM "); n != 2 {
+					t.Errorf("%d run headers, want 2", n)
+				}
+				if n := strings.Count(string(data), "OPEN S3 BUCKET: "); n != 2 {
+					t.Errorf("%d findings, want 2", n)
+				}
+			case "csv":
+				rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(rows) != 3 || !slices.Equal(rows[0], logColumns) || !slices.Equal(rows[2], logRow(testFinding)) {
+					t.Errorf("got rows %q, want the header once and two findings", rows)
+				}
+			case "json":
+				findings, err := ReadJSONLog(bytes.NewReader(data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(findings) != 2 {
+					t.Errorf("%d findings, want 2", len(findings))
+				}
+			}
+		})
+	}
+}
+
+func TestCheckCSVColumns(t *testing.T) {
+	tests := []struct {
+		name, data string
+		fail       bool
+	}{
+		{"empty", "", false},
+		{"same columns", strings.Join(logColumns, ",") + "\n", false},
+		{"older columns", "msg,target,access\nOPEN S3 BUCKET,http://acme.s3.amazonaws.com,public\n", true},
+		{"extra column", strings.Join(logColumns, ",") + ",extra\n", true},
+		{"not csv", "\"unterminated\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "log.csv")
+			if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			s, err := OpenSink("csv", path)
+			if tt.fail {
+				if err == nil || !strings.Contains(err.Error(), "different csv columns") {
+					t.Fatalf("error %v, want the file refused", err)
+				}
+				data, _ := os.ReadFile(path)
+				if string(data) != tt.data {
+					t.Errorf("refused file was changed to %q", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.Close()
+		})
+	}
+
+	if err := checkCSVColumns(filepath.Join(t.TempDir(), "new.csv")); err != nil {
+		t.Errorf("missing file: %v", err)
+	}
+}
+
+func TestParseSinkSpec(t *testing.T) {
+	tests := []struct {
+		spec, format, path string
+		fail               bool
+	}{
+		{"-", "json", "-", false},
+		{"json:-", "json", "-", false},
+		{"jsonl:out.jsonl", "json", "out.jsonl", false},
+		{"csv:/tmp/out.csv", "csv", "/tmp/out.csv", false},
+		{"text:C:\\logs\\out.txt", "text", "C:\\logs\\out.txt", false},
+		{"csv", "", "", true},
+		{"csv:", "", "", true},
+		{"xml:out.xml", "", "", true},
+	}
+	for _, tt := range tests {
+		format, path, err := ParseSinkSpec(tt.spec)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("%s: got %s %s, want an error", tt.spec, format, path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.spec, err)
+		} else if format != tt.format || path != tt.path {
+			t.Errorf("%s: got %s %s, want %s %s", tt.spec, format, path, tt.format, tt.path)
+		}
+	}
+}
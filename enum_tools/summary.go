@@ -0,0 +1,273 @@
+package enum_tools
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Summaries
+// ---------------------------------------------------------------------------
+
+// summarySections lists the access levels a summary has a table for, in
+// order; findings with any other access level go under "Other findings".
+var summarySections = []struct{ access, title string }{
+	{"public", "Public resources"},
+	{"unclaimed", "Unclaimed resources (potential takeovers)"},
+	{"protected", "Protected resources"},
+	{"disabled", "Disabled resources"},
+}
+
+// summary writes a document either as Markdown or as plain text.
+type summary struct {
+	w        io.Writer
+	markdown bool
+}
+
+func (s *summary) heading(level int, title string) {
+	if s.markdown {
+		fmt.Fprintf(s.w, "%s %s\n\n", strings.Repeat("#", level), title)
+		return
+	}
+	underline := "="
+	if level > 1 {
+		underline = "-"
+	}
+	fmt.Fprintf(s.w, "%s\n%s\n\n", title, strings.Repeat(underline, len(title)))
+}
+
+func (s *summary) para(text string) {
+	fmt.Fprintf(s.w, "%s\n\n", text)
+}
+
+func (s *summary) list(items []string) {
+	for _, item := range items {
+		if s.markdown {
+			item = mdEscape(item)
+		}
+		fmt.Fprintf(s.w, "- %s\n", item)
+	}
+	fmt.Fprintln(s.w)
+}
+
+func (s *summary) table(headers []string, rows [][]string) {
+	if s.markdown {
+		fmt.Fprintf(s.w, "| %s |\n", strings.Join(headers, " | "))
+		fmt.Fprintf(s.w, "|%s\n", strings.Repeat(" --- |", len(headers)))
+		for _, row := range rows {
+			cells := make([]string, len(row))
+			for i, c := range row {
+				cells[i] = strings.ReplaceAll(mdEscape(c), "|", `\|`)
+			}
+			fmt.Fprintf(s.w, "| %s |\n", strings.Join(cells, " | "))
+		}
+		fmt.Fprintln(s.w)
+		return
+	}
+	tw := tabwriter.NewWriter(s.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+	fmt.Fprintln(s.w)
+}
+
+// mdEscape keeps text from being read as Markdown markup.
+func mdEscape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "<", "&lt;").Replace(text)
+}
+
+// WriteSummary writes an executive summary of findings to w, as Markdown
+// or as plain text: counts by provider and access level, tables of the
+// public, unclaimed, protected and disabled resources in that order, the
+// scan's configuration and its notable errors.
+func WriteSummary(w io.Writer, markdown bool, info ReportInfo, findings []Finding) {
+	s := &summary{w: w, markdown: markdown}
+	s.heading(1, "cloud_enum summary")
+
+	var intro []string
+	if info.RunID != "" {
+		intro = append(intro, "Run "+info.RunID)
+	}
+	if !info.Started.IsZero() {
+		intro = append(intro, "started "+info.Started.Format("2006-01-02 15:04 MST"))
+	}
+	if d := info.Duration; d >= time.Second {
+		intro = append(intro, "took "+d.Round(time.Second).String())
+	} else if d > 0 {
+		intro = append(intro, "took "+d.Round(time.Millisecond).String())
+	}
+	text := fmt.Sprintf("%d finding(s)", len(findings))
+	if len(intro) > 0 {
+		text = strings.Join(intro, ", ") + ": " + text
+	}
+	if info.Interrupted {
+		text += ". The scan was interrupted, so the results are partial"
+	}
+	s.para(text + ".")
+
+	// Counts by provider and access level.
+	if len(findings) > 0 {
+		counts := countFindings(findings)
+		headers := []string{"Provider"}
+		for _, c := range counts {
+			headers = append(headers, c.Access)
+		}
+		headers = append(headers, "Total")
+		perProvider := make(map[string]map[string]int)
+		var providers []string
+		for _, f := range findings {
+			if perProvider[f.Platform] == nil {
+				perProvider[f.Platform] = make(map[string]int)
+				providers = append(providers, f.Platform)
+			}
+			perProvider[f.Platform][f.Access]++
+		}
+		sort.Strings(providers)
+		var rows [][]string
+		for _, p := range providers {
+			row := []string{reportProviderName(p)}
+			total := 0
+			for _, c := range counts {
+				n := perProvider[p][c.Access]
+				row = append(row, fmt.Sprint(n))
+				total += n
+			}
+			rows = append(rows, append(row, fmt.Sprint(total)))
+		}
+		total := []string{"Total"}
+		for _, c := range counts {
+			total = append(total, fmt.Sprint(c.Count))
+		}
+		rows = append(rows, append(total, fmt.Sprint(len(findings))))
+		s.heading(2, "Findings by provider")
+		s.table(headers, rows)
+	}
+
+	// One table per access level, most exposed first.
+	sorted := append([]Finding(nil), findings...)
+	sortFindings(sorted)
+	known := make(map[string]bool)
+	for _, sec := range summarySections {
+		known[sec.access] = true
+		s.findingsTable(sec.title, sorted, func(f Finding) bool { return f.Access == sec.access })
+	}
+	s.findingsTable("Other findings", sorted, func(f Finding) bool { return !known[f.Access] })
+
+	s.heading(2, "Scan configuration")
+	s.list(scanConfig(info))
+
+	if notes := notableErrors(info); len(notes) > 0 {
+		s.heading(2, "Notable errors")
+		s.list(notes)
+	}
+}
+
+// findingsTable writes the findings keep selects, if any, under title.
+func (s *summary) findingsTable(title string, findings []Finding, keep func(Finding) bool) {
+	var rows [][]string
+	for _, f := range findings {
+		if !keep(f) {
+			continue
+		}
+		service := f.Service
+		if service == "" {
+			service = f.Check
+		}
+		rows = append(rows, []string{reportProviderName(f.Platform), service, f.Msg, f.Target, f.Severity})
+	}
+	if len(rows) == 0 {
+		return
+	}
+	s.heading(2, fmt.Sprintf("%s (%d)", title, len(rows)))
+	s.table([]string{"Provider", "Service", "Finding", "Target", "Severity"}, rows)
+}
+
+// scanConfig describes the scan a summary covers.
+func scanConfig(info ReportInfo) []string {
+	var items []string
+	if info.Mode != "" {
+		items = append(items, "Mode: "+info.Mode)
+	}
+	if len(info.Keywords) > 0 {
+		items = append(items, "Keywords: "+strings.Join(info.Keywords, ", "))
+	}
+	if info.Mode == "keywords" {
+		items = append(items, fmt.Sprintf("Mutations: %d", info.Mutations))
+	}
+	if info.Targets > 0 {
+		items = append(items, fmt.Sprintf("Names scanned: %d", info.Targets))
+	}
+	providers := make([]string, 0, len(info.Regions))
+	for p := range info.Regions {
+		providers = append(providers, p)
+	}
+	sort.Strings(providers)
+	for _, p := range providers {
+		items = append(items, reportProviderName(p)+" regions: "+strings.Join(info.Regions[p], ", "))
+	}
+	if len(info.Timings) > 0 {
+		var checks []string
+		for _, t := range info.Timings {
+			checks = append(checks, t.Check)
+		}
+		items = append(items, "Checks: "+strings.Join(checks, ", "))
+	}
+	if st := info.Stats; st.Requests > 0 || st.Lookups > 0 {
+		items = append(items, fmt.Sprintf("HTTP requests: %d sent, %d throttled, %d retried", st.Requests, st.Throttled, st.Retried))
+		items = append(items, fmt.Sprintf("DNS lookups: %d", st.Lookups))
+	}
+	if len(items) == 0 {
+		items = append(items, "Not recorded")
+	}
+	return items
+}
+
+// notableErrors lists what went wrong during the scan.
+func notableErrors(info ReportInfo) []string {
+	notes := append([]string(nil), info.Errors...)
+	if info.Stats.GaveUp > 0 {
+		notes = append(notes, fmt.Sprintf("%d candidate(s) given up after repeated throttling", info.Stats.GaveUp))
+	}
+	if info.Stats.LookupFailures > 0 {
+		notes = append(notes, fmt.Sprintf("%d DNS lookup(s) failed (SERVFAIL / timeout)", info.Stats.LookupFailures))
+	}
+	return notes
+}
+
+func reportProviderName(p string) string {
+	if name, ok := providerNames[p]; ok {
+		return name
+	}
+	return p
+}
+
+// ReportInfoFromLog reconstructs what a log of findings tells about the
+// scans behind them: their run IDs, keywords and when they started.
+func ReportInfoFromLog(findings []Finding) ReportInfo {
+	var info ReportInfo
+	var runs, keywords []string
+	seenRun := make(map[string]bool)
+	seenKeyword := make(map[string]bool)
+	for _, f := range findings {
+		if f.RunID != "" && !seenRun[f.RunID] {
+			seenRun[f.RunID] = true
+			runs = append(runs, f.RunID)
+		}
+		if f.Keyword != "" && !seenKeyword[f.Keyword] {
+			seenKeyword[f.Keyword] = true
+			keywords = append(keywords, f.Keyword)
+		}
+		if !f.Time.IsZero() && (info.Started.IsZero() || f.Time.Before(info.Started)) {
+			info.Started = f.Time // first finding, close enough
+		}
+	}
+	info.RunID = strings.Join(runs, ", ")
+	info.Keywords = keywords
+	return info
+}
@@ -0,0 +1,259 @@
+package enum_tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ---------------------------------------------------------------------------
+// Takeover services
+// ---------------------------------------------------------------------------
+
+// takeoverService is a cloud service a subdomain can point at with a CNAME.
+// Whether the referenced resource is still claimed is decided by one of the
+// existing checks.
+type takeoverService struct {
+	zone     string   // CNAME targets under this zone point at the service
+	aliases  []string // further zones of the service; {region} matches within a label
+	provider string
+	check    string // existence check reused for the resource
+	kind     string // printed as "unclaimed <kind>"
+	selfName string // target label meaning "named after the subdomain"
+	undotted bool   // resource names can't contain dots
+}
+
+var takeoverServices = []takeoverService{
+	{zone: s3URL, provider: "aws", check: "aws-s3", kind: "S3 bucket", aliases: []string{
+		"s3.{region}.amazonaws.com", "s3-{region}.amazonaws.com", "s3.dualstack.{region}.amazonaws.com",
+		"s3-website-{region}.amazonaws.com", "s3-website.{region}.amazonaws.com",
+	}},
+	{zone: blobURL, provider: "azure", check: "azure-blob", kind: "Azure storage account"},
+	{zone: webappURL, provider: "azure", check: "azure-websites", kind: "Azure website"},
+	{zone: vmURL, provider: "azure", check: "azure-vms", kind: "Azure virtual machine name"},
+	{zone: gcpURL, provider: "gcp", check: "gcp-buckets", kind: "Google bucket", selfName: "c"},
+	{zone: appspotURL, provider: "gcp", check: "gcp-appspot", kind: "Google App Engine app", undotted: true},
+	{zone: fbappURL, provider: "gcp", check: "gcp-firebase-app", kind: "Google Firebase app", undotted: true},
+}
+
+// takeoverRef is a subdomain whose CNAME chain enters a service's zone.
+type takeoverRef struct {
+	ans  *DNSAnswer
+	host string // first name of the chain inside the zone
+	name string // resource (bucket, account, app) the host refers to
+}
+
+// cutZone returns what precedes the service's zone, or one of its
+// aliases, in host.
+func (ts *takeoverService) cutZone(host string) (prefix string, ok bool) {
+	hostLabels := strings.Split(host, ".")
+	for _, zone := range append([]string{ts.zone}, ts.aliases...) {
+		zoneLabels := strings.Split(zone, ".")
+		n := len(hostLabels) - len(zoneLabels)
+		if n < 0 {
+			continue
+		}
+		match := true
+		for i, label := range zoneLabels {
+			match = match && matchLabel(hostLabels[n+i], label)
+		}
+		if match {
+			return strings.Join(hostLabels[:n], "."), true
+		}
+	}
+	return "", false
+}
+
+// matchLabel reports whether a host label matches a zone label, in which
+// {region} stands for any non-empty text.
+func matchLabel(host, label string) bool {
+	before, after, wild := strings.Cut(label, "{region}")
+	if !wild {
+		return host == label
+	}
+	return len(host) > len(before)+len(after) && strings.HasPrefix(host, before) && strings.HasSuffix(host, after)
+}
+
+// resourceName returns the resource a host in the service's zone refers
+// to, given what precedes the zone. Buckets serving a website are named
+// after the subdomain itself.
+func (ts *takeoverService) resourceName(subdomain, prefix string) string {
+	if prefix == "" || prefix == ts.selfName {
+		return subdomain
+	}
+	if ts.undotted {
+		// service.project.appspot.com
+		prefix = prefix[strings.LastIndex(prefix, ".")+1:]
+	}
+	return prefix
+}
+
+// findTakeoverRefs matches every CNAME chain against the known services.
+func findTakeoverRefs(answers []*DNSAnswer) map[*takeoverService][]takeoverRef {
+	refs := make(map[*takeoverService][]takeoverRef)
+	for _, ans := range answers {
+	chain:
+		for _, host := range ans.CNAMEs {
+			for i := range takeoverServices {
+				ts := &takeoverServices[i]
+				if prefix, ok := ts.cutZone(host); ok {
+					refs[ts] = append(refs[ts], takeoverRef{ans, host, ts.resourceName(ans.Name, prefix)})
+					break chain
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// ---------------------------------------------------------------------------
+// Takeover scan
+// ---------------------------------------------------------------------------
+
+// Takeover resolves a target's own subdomains and reports the ones whose
+// CNAME chain points at a cloud resource that no longer exists, which
+// anyone could register to serve content under the subdomain. Findings
+// carry the chain as evidence and are delivered to fn like Run's.
+func (s *Scanner) Takeover(ctx context.Context, subdomains []string, fn func(Finding)) error {
+	defer s.session("takeover", fn)()
+
+	s.Printf("[+] Resolving %d subdomains\n", len(subdomains))
+	if err := s.checkResolvers(ctx); err != nil {
+		return err
+	}
+	answers, err := s.resolveAll(ctx, subdomains)
+	if err != nil {
+		return err
+	}
+
+	refs := findTakeoverRefs(answers)
+	total := 0
+	for _, r := range refs {
+		total += len(r)
+	}
+	s.Printf("[*] %d of them point at known cloud services\n", total)
+
+	for i := range takeoverServices {
+		ts := &takeoverServices[i]
+		if len(refs[ts]) == 0 {
+			continue
+		}
+		s.Printf("[+] Checking %d reference(s) to %s\n", len(refs[ts]), ts.zone)
+		s.mu.Lock()
+		s.provider = ts.provider
+		s.service = ""
+		if chk, ok := LookupCheck(ts.check); ok {
+			if chk, ok := chk.(*check); ok {
+				s.service = chk.service
+			}
+		}
+		s.mu.Unlock()
+
+		unclaimed, err := s.unclaimed(ctx, ts, refs[ts])
+		if err != nil {
+			return err
+		}
+		for _, ref := range refs[ts] {
+			if unclaimed[ref.name] {
+				s.emitResolved(OutputData{
+					Platform: ts.provider,
+					Msg:      "Potential takeover, unclaimed " + ts.kind + " " + ref.name,
+					Target:   ref.ans.Name,
+					Access:   "unclaimed",
+				}, ref.ans)
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// unclaimed returns the resources referenced by refs that don't exist.
+// DNS-only services exist when their name resolves, as in their checks, so
+// the chain already tells: it ends in NXDOMAIN at the service's name. The
+// others are probed with their check's own candidates, and a resource is
+// missing when it answers 404, the reply those checks skip as not found.
+func (s *Scanner) unclaimed(ctx context.Context, ts *takeoverService, refs []takeoverRef) (map[string]bool, error) {
+	out := make(map[string]bool)
+	c, ok := LookupCheck(ts.check)
+	if !ok {
+		return nil, fmt.Errorf("takeover: unknown check %q", ts.check)
+	}
+	chk, ok := c.(*check)
+	if !ok || dnsServices[chk.service] {
+		for _, ref := range refs {
+			if ref.host == ref.ans.Target() && ref.ans.Rcode == "NXDOMAIN" {
+				out[ref.name] = true
+			}
+		}
+		return out, nil
+	}
+
+	urlOf := make(map[string]string)
+	var urls []string
+	for _, ref := range refs {
+		for _, u := range c.Candidates([]string{ref.name}, &s.cfg, nil) {
+			if _, dup := urlOf[u]; !dup {
+				urlOf[u] = ref.name
+				urls = append(urls, u)
+			}
+		}
+	}
+	var mu sync.Mutex
+	err := s.GetURLBatch(ctx, urls, false, func(result *HttpResult) bool {
+		if result.StatusCode == 404 {
+			mu.Lock()
+			out[urlOf[result.OriginalURL]] = true
+			mu.Unlock()
+		}
+		return false
+	}, true)
+	return out, err
+}
+
+// resolveAll resolves every name in order, keeping negative answers and
+// dropping failed lookups.
+func (s *Scanner) resolveAll(ctx context.Context, names []string) ([]*DNSAnswer, error) {
+	answers := make([]*DNSAnswer, len(names))
+	errs := make([]error, len(names))
+	sem := make(chan struct{}, min(s.cfg.Threads*10, 500))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			answers[i], errs[i] = s.lookup(ctx, name)
+		}(i, name)
+	}
+	wg.Wait()
+
+	var resolved []*DNSAnswer
+	for i, ans := range answers {
+		if errors.Is(errs[i], ErrNameserver) {
+			return nil, errs[i]
+		}
+		if ans == nil {
+			continue
+		}
+		switch ans.Rcode {
+		case "REFUSED", "NOTIMP":
+			return nil, fmt.Errorf("%w: %s answered %s for %s", ErrNameserver, ans.Server, ans.Rcode, ans.Name)
+		case "NOERROR", "NXDOMAIN":
+		default:
+			s.stats.lookupFailures.Add(1)
+			s.Printf("    [!] Lookup of %s failed: %s\n", ans.Name, ans.Rcode)
+			continue
+		}
+		resolved = append(resolved, ans)
+	}
+	return resolved, ctx.Err()
+}
@@ -0,0 +1,43 @@
+package enum_tools
+
+import "testing"
+
+func TestFindTakeoverRefs(t *testing.T) {
+	tests := []struct {
+		cname      string
+		zone, name string // "" when no service matches
+	}{
+		{"acme-assets.s3.amazonaws.com", s3URL, "acme-assets"},
+		{"acme-assets.s3.eu-west-1.amazonaws.com", s3URL, "acme-assets"},
+		{"acme-assets.s3-eu-west-1.amazonaws.com", s3URL, "acme-assets"},
+		{"acme-assets.s3.dualstack.us-east-2.amazonaws.com", s3URL, "acme-assets"},
+		{"www.acme.com.s3-website-us-east-1.amazonaws.com", s3URL, "www.acme.com"},
+		{"www.acme.com.s3-website.eu-central-1.amazonaws.com", s3URL, "www.acme.com"},
+		{"s3.amazonaws.com", s3URL, "www.acme.com"},
+		{"acme.ec2.eu-west-1.amazonaws.com", "", ""},
+		{"s3.eu-west-1.amazonaws.com.evil.net", "", ""},
+		{"acmeblob.blob.core.windows.net", blobURL, "acmeblob"},
+		{"c.storage.googleapis.com", gcpURL, "www.acme.com"},
+		{"api.acme-prod.appspot.com", appspotURL, "acme-prod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cname, func(t *testing.T) {
+			ans := &DNSAnswer{Name: "www.acme.com", CNAMEs: []string{tt.cname}, Rcode: "NXDOMAIN"}
+			refs := findTakeoverRefs([]*DNSAnswer{ans})
+			if tt.zone == "" {
+				if len(refs) != 0 {
+					t.Errorf("got %v, want no service", refs)
+				}
+				return
+			}
+			for ts, r := range refs {
+				if ts.zone != tt.zone || len(r) != 1 || r[0].name != tt.name || r[0].host != tt.cname {
+					t.Errorf("got %s %+v, want %s resource %s", ts.zone, r, tt.zone, tt.name)
+				}
+			}
+			if len(refs) != 1 {
+				t.Errorf("%d services matched, want 1", len(refs))
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package enum_tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const customBanner = `
+++++++++++++++++++++++++++
+      custom checks
+++++++++++++++++++++++++++
+`
+
+// ---------------------------------------------------------------------------
+// Templates
+// ---------------------------------------------------------------------------
+
+// Template declares a check without code: a URL or DNS pattern expanded
+// for every name, and matchers (rules, as in rules.json) reporting the
+// responses.
+type Template struct {
+	Name      string   `json:"name"`      // check name, e.g. "custom-gitlab-pages"
+	Title     string   `json:"title"`     // printed as "[+] Checking for <title>"
+	Provider  string   `json:"provider"`  // aws, azure, gcp or custom (default)
+	Protocol  string   `json:"protocol"`  // http, https or dns
+	Pattern   string   `json:"pattern"`   // host[/path] with {name}, {region}, {brute}
+	Regions   []string `json:"regions"`   // values of {region}
+	Redirects bool     `json:"redirects"` // follow HTTP redirects
+	Matchers  []*rule  `json:"matchers"`
+}
+
+// LoadTemplates reads every *.json template in dir and returns the checks
+// they declare, ready to be registered.
+func LoadTemplates(dir string) ([]Check, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no templates (*.json) in %s", dir)
+	}
+	sort.Strings(paths)
+
+	var checks []Check
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var t Template
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, fmt.Errorf("template %s: %w", path, err)
+		}
+		c, err := t.check()
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", path, err)
+		}
+		if _, dup := LookupCheck(c.name); dup || seen[c.name] {
+			return nil, fmt.Errorf("template %s: a check named %q already exists", path, c.name)
+		}
+		seen[c.name] = true
+		checks = append(checks, c)
+	}
+	return checks, nil
+}
+
+// check validates the template and builds its check.
+func (t *Template) check() (*check, error) {
+	if t.Name == "" {
+		return nil, fmt.Errorf("missing name")
+	}
+	if t.Provider == "" {
+		t.Provider = "custom"
+	}
+	if _, ok := providerBanners[t.Provider]; !ok {
+		return nil, fmt.Errorf("unknown provider %q", t.Provider)
+	}
+	if t.Title == "" {
+		t.Title = t.Name
+	}
+	if !strings.Contains(t.Pattern, "{name}") {
+		return nil, fmt.Errorf("pattern must contain {name}")
+	}
+	if strings.Contains(t.Pattern, "{region}") != (len(t.Regions) > 0) {
+		return nil, fmt.Errorf("regions must be given when, and only when, the pattern uses {region}")
+	}
+	if len(t.Matchers) == 0 {
+		return nil, fmt.Errorf("no matchers")
+	}
+
+	c := &check{
+		name:     t.Name,
+		provider: t.Provider,
+		title:    t.Title,
+		build:    t.candidates,
+		template: t,
+	}
+	for i, m := range t.Matchers {
+		if err := m.compile(); err != nil {
+			return nil, fmt.Errorf("matcher %d: %w", i+1, err)
+		}
+		httpOnly := len(m.Status) > 0 || m.Reason != "" || m.Body != "" || m.Text != "" ||
+			len(m.Headers) > 0 || m.URL != "" || m.List != "" || m.Breakout
+		if t.Protocol == "dns" && httpOnly {
+			return nil, fmt.Errorf("matcher %d: dns templates can only match on cname", i+1)
+		}
+		if t.Protocol != "dns" && m.CNAME != "" {
+			return nil, fmt.Errorf("matcher %d: cname only applies to dns templates", i+1)
+		}
+	}
+
+	switch t.Protocol {
+	case "http", "https":
+		c.classify = func(s *Scanner, result *HttpResult) bool {
+			return applyRules(s, t.Matchers, t.Provider, result)
+		}
+		c.run = httpRun(t.Redirects)
+	case "dns":
+		if strings.Contains(t.Pattern, "/") {
+			return nil, fmt.Errorf("dns pattern must be a hostname")
+		}
+		c.resolved = t.report
+		c.run = dnsRun
+	default:
+		return nil, fmt.Errorf("protocol must be http, https or dns")
+	}
+	return c, nil
+}
+
+// candidates expands the pattern for every name, region and brute-force
+// word. Patterns using {brute} are second-level scans, skipped by
+// QuickScan.
+func (t *Template) candidates(names []string, cfg *Config, _ map[string][]string) []string {
+	regions := t.Regions
+	if len(regions) == 0 {
+		regions = []string{""}
+	}
+	brute := []string{""}
+	if strings.Contains(t.Pattern, "{brute}") {
+		if cfg.QuickScan {
+			return nil
+		}
+		brute = GetBrute(cfg.BruteData, 1, 63)
+	}
+	prefix := ""
+	if t.Protocol != "dns" {
+		prefix = t.Protocol + "://"
+	}
+
+	var candidates []string
+	for _, region := range regions {
+		for _, name := range names {
+			for _, word := range brute {
+				r := strings.NewReplacer("{name}", name, "{region}", region, "{brute}", word)
+				candidates = append(candidates, prefix+r.Replace(t.Pattern))
+			}
+		}
+	}
+	return candidates
+}
+
+// report handles a resolved name of a dns template: the first matcher
+// whose cname condition holds decides.
+func (t *Template) report(s *Scanner, ans *DNSAnswer) {
+	for _, m := range t.Matchers {
+		if !m.matchesDNS(ans) {
+			continue
+		}
+		if m.Warn != "" {
+			s.Printf("    %s\n", strings.ReplaceAll(m.Warn, "{url}", ans.Name))
+		}
+		if m.Msg != "" {
+			target := strings.ReplaceAll(m.Target, "{url}", ans.Name)
+			if target == "" {
+				target = ans.Name
+			}
+			s.emitResolved(OutputData{
+				Platform: t.Provider,
+				Msg:      m.Msg,
+				Target:   target,
+				Access:   m.Access,
+				Severity: m.Severity,
+			}, ans)
+		}
+		return
+	}
+}
@@ -0,0 +1,148 @@
+package enum_tools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// gitlabTemplate is the example template of the README.
+const gitlabTemplate = `{
+  "name": "custom-gitlab-pages",
+  "title": "GitLab Pages sites",
+  "protocol": "https",
+  "pattern": "{name}.gitlab.io",
+  "matchers": [
+    {"status": [404]},
+    {"status": [200], "msg": "GitLab Pages site", "access": "public"}
+  ]
+}`
+
+func TestTemplateCheck(t *testing.T) {
+	tests := []struct {
+		name, json string
+		err        string // "" for a valid template
+	}{
+		{"README example", gitlabTemplate, ""},
+		{"dns", `{"name":"t","protocol":"dns","pattern":"{name}.example.net","matchers":[{"cname":"\\.example\\.org$","msg":"Alias","access":"public"}]}`, ""},
+		{"regions", `{"name":"t","protocol":"http","pattern":"{name}.{region}.example.net","regions":["eu","us"],"matchers":[{"status":[200],"msg":"Site","access":"public"}]}`, ""},
+		{"missing name", `{"protocol":"https","pattern":"{name}.example.net","matchers":[{"status":[200]}]}`, "missing name"},
+		{"unknown provider", `{"name":"t","provider":"oracle","protocol":"https","pattern":"{name}.example.net","matchers":[{"status":[200]}]}`, "unknown provider"},
+		{"no {name}", `{"name":"t","protocol":"https","pattern":"www.example.net","matchers":[{"status":[200]}]}`, "pattern must contain {name}"},
+		{"{region} without regions", `{"name":"t","protocol":"https","pattern":"{name}.{region}.example.net","matchers":[{"status":[200]}]}`, "regions must be given"},
+		{"regions without {region}", `{"name":"t","protocol":"https","pattern":"{name}.example.net","regions":["eu"],"matchers":[{"status":[200]}]}`, "regions must be given"},
+		{"no matchers", `{"name":"t","protocol":"https","pattern":"{name}.example.net"}`, "no matchers"},
+		{"bad protocol", `{"name":"t","protocol":"ftp","pattern":"{name}.example.net","matchers":[{"status":[200]}]}`, "protocol must be http, https or dns"},
+		{"missing protocol", `{"name":"t","pattern":"{name}.example.net","matchers":[{"status":[200]}]}`, "protocol must be http, https or dns"},
+		{"dns with a path", `{"name":"t","protocol":"dns","pattern":"{name}.example.net/x","matchers":[{"cname":"x"}]}`, "dns pattern must be a hostname"},
+		{"dns matching status", `{"name":"t","protocol":"dns","pattern":"{name}.example.net","matchers":[{"status":[200]}]}`, "matcher 1: dns templates can only match on cname"},
+		{"http matching cname", `{"name":"t","protocol":"https","pattern":"{name}.example.net","matchers":[{"status":[404]},{"cname":"x"}]}`, "matcher 2: cname only applies to dns templates"},
+		{"bad regexp", `{"name":"t","protocol":"https","pattern":"{name}.example.net","matchers":[{"body":"("}]}`, "matcher 1:"},
+		{"msg without access", `{"name":"t","protocol":"https","pattern":"{name}.example.net","matchers":[{"status":[200],"msg":"Site"}]}`, "msg needs an access"},
+		{"unknown access", `{"name":"t","protocol":"https","pattern":"{name}.example.net","matchers":[{"status":[200],"msg":"Site","access":"open"}]}`, "unknown access"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tmpl Template
+			if err := json.Unmarshal([]byte(tt.json), &tmpl); err != nil {
+				t.Fatal(err)
+			}
+			c, err := tmpl.check()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.name != tmpl.Name || c.provider != "custom" || c.title == "" {
+				t.Errorf("check %s, provider %s, title %q", c.name, c.provider, c.title)
+			}
+		})
+	}
+}
+
+func TestTemplateCandidates(t *testing.T) {
+	tests := []struct {
+		name, json string
+		quick      bool
+		want       []string
+	}{
+		{"https", gitlabTemplate, false, []string{"https://acme.gitlab.io", "https://acme-dev.gitlab.io"}},
+		{"dns", `{"name":"t","protocol":"dns","pattern":"{name}.example.net","matchers":[{"cname":"x"}]}`, false,
+			[]string{"acme.example.net", "acme-dev.example.net"}},
+		{"regions", `{"name":"t","protocol":"http","pattern":"{name}.{region}.example.net/","regions":["eu","us"],"matchers":[{"status":[200]}]}`, false,
+			[]string{"http://acme.eu.example.net/", "http://acme-dev.eu.example.net/", "http://acme.us.example.net/", "http://acme-dev.us.example.net/"}},
+		{"brute skipped by quick scans", `{"name":"t","protocol":"https","pattern":"{name}.example.net/{brute}","matchers":[{"status":[200]}]}`, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tmpl Template
+			if err := json.Unmarshal([]byte(tt.json), &tmpl); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tmpl.check(); err != nil {
+				t.Fatal(err)
+			}
+			got := tmpl.candidates([]string{"acme", "acme-dev"}, &Config{QuickScan: tt.quick}, nil)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	write := func(t *testing.T, dir, file, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other := strings.Replace(gitlabTemplate, "custom-gitlab-pages", "custom-other", 1)
+
+	dir := t.TempDir()
+	write(t, dir, "b.json", gitlabTemplate)
+	write(t, dir, "a.json", other)
+	write(t, dir, "notes.txt", "not a template")
+	checks, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range checks {
+		names = append(names, c.Name())
+	}
+	if want := []string{"custom-other", "custom-gitlab-pages"}; !slices.Equal(names, want) {
+		t.Errorf("got %q, want %q in file order", names, want)
+	}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   string
+	}{
+		{"empty directory", nil, "no templates"},
+		{"invalid JSON", map[string]string{"a.json": "{"}, "a.json"},
+		{"invalid template", map[string]string{"a.json": `{"name":"t"}`}, "a.json: pattern must contain {name}"},
+		{"duplicate name", map[string]string{"a.json": gitlabTemplate, "b.json": gitlabTemplate}, `b.json: a check named "custom-gitlab-pages" already exists`},
+		{"built-in name", map[string]string{"a.json": strings.Replace(gitlabTemplate, "custom-gitlab-pages", "aws-s3", 1)}, `a check named "aws-s3" already exists`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for file, data := range tt.files {
+				write(t, dir, file, data)
+			}
+			_, err := LoadTemplates(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -3,14 +3,15 @@ package enum_tools
 import (
 	"bufio"
 	"context"
-	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -18,51 +19,18 @@ import (
 )
 
 // ---------------------------------------------------------------------------
-// Logging globals
+// Context helpers
 // ---------------------------------------------------------------------------
 
-var (
-	logFile   string
-	logFormat string
-	logMu     sync.Mutex
-)
-
-// ---------------------------------------------------------------------------
-// Rate-limiter globals
-// ---------------------------------------------------------------------------
-
-var (
-	rlCount     int64         // total HTTP requests made (across all batches)
-	rlThreshold int64         // sleep every N requests (0 = disabled)
-	rlSleep     time.Duration // duration to sleep
-	rlPaused    int64         // 1 while sleeping, 0 otherwise
-)
-
-// InitRateLimiter configures the global HTTP rate limiter.
-func InitRateLimiter(threshold int, sleep time.Duration) {
-	rlThreshold = int64(threshold)
-	rlSleep = sleep
-}
-
-// rateLimitCheck must be called before every HTTP request. When the
-// cumulative request count crosses a multiple of the threshold the
-// calling goroutine sleeps (other workers spin-wait until it's done).
-func rateLimitCheck() {
-	if rlThreshold <= 0 {
-		return
-	}
-
-	// Wait while another goroutine is sleeping.
-	for atomic.LoadInt64(&rlPaused) != 0 {
-		time.Sleep(100 * time.Millisecond)
-	}
-
-	count := atomic.AddInt64(&rlCount, 1)
-	if count%rlThreshold == 0 {
-		atomic.StoreInt64(&rlPaused, 1)
-		fmt.Printf("\n    [*] Rate limit: %d requests done, sleeping %v...\n", count, rlSleep)
-		time.Sleep(rlSleep)
-		atomic.StoreInt64(&rlPaused, 0)
+// sleepCtx sleeps for d or until ctx is done.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
@@ -76,6 +44,58 @@ type OutputData struct {
 	Msg      string `json:"msg"`
 	Target   string `json:"target"`
 	Access   string `json:"access"`
+	Severity string `json:"severity,omitempty"` // info, low, medium or high
+}
+
+// FindingVersion is bumped whenever the Finding layout changes, so log
+// consumers can tell the schemas apart.
+const FindingVersion = 2
+
+// Finding is what a Scanner emits for every discovered resource, with
+// where it came from and the evidence behind it.
+type Finding struct {
+	Version int `json:"version"` // FindingVersion
+	OutputData
+	Check     string        `json:"check"`               // name of the check that found it
+	Service   string        `json:"service,omitempty"`   // endpoint service probed, e.g. "s3"
+	Name      string        `json:"name,omitempty"`      // mutated name the candidate was built from
+	Keyword   string        `json:"keyword,omitempty"`   // keyword behind the name
+	Mutation  string        `json:"mutation,omitempty"`  // mutation behind the name ("" for the bare keyword)
+	Candidate string        `json:"candidate,omitempty"` // URL or hostname probed
+	HTTP      *HTTPEvidence `json:"http,omitempty"`      // response it was classified from
+	DNS       *DNSAnswer    `json:"dns,omitempty"`       // answer it was classified from
+	Files     []string      `json:"files,omitempty"`     // listed bucket / container keys
+	Time      time.Time     `json:"time"`                // when it was found
+	RunID     string        `json:"run_id"`              // scan that found it
+	Restored  bool          `json:"-"`                   // replayed from a resumed state file
+}
+
+// HTTPEvidence is the part of a response kept with a finding.
+type HTTPEvidence struct {
+	URL     string            `json:"url"` // after redirects
+	Status  int               `json:"status"`
+	Reason  string            `json:"reason"`
+	Headers map[string]string `json:"headers,omitempty"` // the evidenceHeaders present
+}
+
+// evidenceHeaders are the response headers worth keeping: they identify
+// the service and explain the status.
+var evidenceHeaders = []string{
+	"Server", "Content-Type", "Location", "WWW-Authenticate",
+	"X-Amz-Bucket-Region", "X-Ms-Error-Code", "X-Guploader-Uploadid",
+}
+
+func newHTTPEvidence(result *HttpResult) *HTTPEvidence {
+	e := &HTTPEvidence{URL: result.URL, Status: result.StatusCode, Reason: result.Reason}
+	for _, name := range evidenceHeaders {
+		if v := result.Header.Get(name); v != "" {
+			if e.Headers == nil {
+				e.Headers = make(map[string]string)
+			}
+			e.Headers[name] = v
+		}
+	}
+	return e
 }
 
 // HttpResult is the data handed to HTTP-callback functions.
@@ -84,44 +104,106 @@ type HttpResult struct {
 	StatusCode  int
 	Reason      string // HTTP reason phrase (text after status code)
 	Body        string
-	OriginalURL string // URL before any redirects
+	OriginalURL string      // URL before any redirects
+	Header      http.Header // response headers
+
+	ctx context.Context // of the request, for follow-up requests such as listings
 }
 
 // Config groups the runtime settings shared across check modules.
 type Config struct {
-	Threads        int
-	Nameserver     string
-	NameserverFile string
-	BruteData      string // raw content of the brute-force wordlist
-	QuickScan      bool
-	RateLimitReqs  int           // sleep after this many HTTP requests (0 = disabled)
-	RateLimitSleep time.Duration // how long to sleep when the threshold is hit
+	Threads            int
+	Nameserver         string
+	NameserverFile     string
+	BruteData          string // raw content of the brute-force wordlist
+	QuickScan          bool
+	RateLimitReqs      int                   // sleep after this many HTTP requests (0 = disabled)
+	RateLimitSleep     time.Duration         // how long to sleep when the threshold is hit
+	RateLimit          RateLimit             // global HTTP budget (zero = unlimited)
+	ProviderRateLimits map[string]RateLimit  // HTTP budgets per provider ("aws", "azure", "gcp")
+	HostRateLimits     map[string]RateLimit  // HTTP budgets per target host suffix
+	Checks             []string              // checks / providers to run (empty = all)
+	SkipChecks         []string              // checks / providers to skip
+	Output             io.Writer             // progress and status messages (nil = discard)
+	StateFile          string                // checkpoint file for resumable scans ("" = disabled)
+	SaveInterval       time.Duration         // how often the checkpoint is written (default 30s)
+	Proxy              string                // http://, https:// or socks5:// proxy for all HTTP traffic
+	ProxyDNS           bool                  // send DNS over TCP through the (socks5) proxy
+	Endpoints          map[string]Endpoint   // per-service endpoint overrides (see DefaultEndpoints)
+	DNSOverride        string                // resolver (host[:port]) used for all lookups and HTTP dials
+	HTTPTimeout        time.Duration         // per request, including the body (default 15s)
+	HTTPRetries        int                   // retries on transient connection errors (0 = none)
+	InsecureTLS        bool                  // skip TLS certificate verification
+	UserAgent          string                // User-Agent header ("" = Go's default)
+	Headers            http.Header           // extra headers sent with every request
+	MaxBodySize        int64                 // bytes of each probe response read (default 8 KB)
+	DNSCache           *DNSCache             // answer cache, shareable between scanners (nil = a private one)
+	Authoritative      bool                  // ask the zones' authoritative nameservers directly
+	AuthoritativeQPS   float64               // queries per second per authoritative server (default 20)
+	IPRanges           *IPRanges             // provider address ranges for Attribute (nil = names only)
+	Rules              *Rules                // HTTP response classification (nil = built-in rules)
+	NameOrigins        map[string]NameOrigin // keyword and mutation of each name, recorded in findings
 }
 
 // ---------------------------------------------------------------------------
 // Logging
 // ---------------------------------------------------------------------------
 
-// InitLogfile sets up the global log file (append mode).
-func InitLogfile(lf, format string) {
-	if lf == "" {
-		return
+// logColumns are the fields of a finding in csv and text logs.
+var logColumns = []string{
+	"version", "time", "run_id", "check", "service", "platform", "msg", "target",
+	"access", "severity", "name", "keyword", "mutation", "candidate",
+	"http_status", "http_reason", "http_headers", "dns_rcode", "dns_cnames", "dns_ips", "files",
+}
+
+// logRow flattens a finding into logColumns.
+func logRow(f Finding) []string {
+	var status, reason, headers, rcode, cnames, ips, files string
+	if f.HTTP != nil {
+		status, reason = strconv.Itoa(f.HTTP.Status), f.HTTP.Reason
+		var hs []string
+		for _, name := range evidenceHeaders {
+			if v, ok := f.HTTP.Headers[name]; ok {
+				hs = append(hs, name+": "+v)
+			}
+		}
+		headers = strings.Join(hs, "; ")
+	}
+	if f.DNS != nil {
+		rcode, cnames, ips = f.DNS.Rcode, strings.Join(f.DNS.CNAMEs, " "), strings.Join(f.DNS.IPs, " ")
+	}
+	if f.Files != nil {
+		files = strconv.Itoa(len(f.Files))
 	}
-	logFile = lf
-	logFormat = format
+	return []string{
+		strconv.Itoa(f.Version), f.Time.Format(time.RFC3339), f.RunID, f.Check, f.Service, f.Platform,
+		f.Msg, f.Target, f.Access, f.Severity, f.Name, f.Keyword, f.Mutation, f.Candidate,
+		status, reason, headers, rcode, cnames, ips, files,
+	}
+}
 
-	now := time.Now().Format("02/01/2006 15:04:05")
-	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		fmt.Printf("[!] Could not open log file: %v\n", err)
-		return
+// ReadJSONLog reads the findings of a json log or of -jsonl output,
+// skipping the run headers between them.
+func ReadJSONLog(r io.Reader) ([]Finding, error) {
+	var findings []Finding
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 64*1024*1024) // listings make long lines
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if !strings.HasPrefix(text, "{") {
+			continue
+		}
+		var f Finding
+		if err := json.Unmarshal([]byte(text), &f); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		findings = append(findings, f)
 	}
-	defer f.Close()
-	fmt.Fprintf(f, "\n\n#### CLOUD_ENUM %s ####\n", now)
+	return findings, sc.Err()
 }
 
-// FmtOutput prints coloured output and optionally logs the finding.
-func FmtOutput(data OutputData) {
+// FmtOutput prints coloured output for a finding to w.
+func FmtOutput(w io.Writer, data OutputData) {
 	bold := "\033[1m"
 	end := "\033[0m"
 	var ansi string
@@ -130,34 +212,30 @@ func FmtOutput(data OutputData) {
 		ansi = bold + "\033[92m" // green
 	case "protected":
 		ansi = bold + "\033[33m" // orange
-	case "disabled":
+	case "disabled", "unclaimed":
 		ansi = bold + "\033[31m" // red
 	default:
 		ansi = bold
 	}
-	fmt.Printf("  %s%s: %s%s\n", ansi, data.Msg, data.Target, end)
+	fmt.Fprintf(w, "  %s%s: %s%s\n", ansi, data.Msg, data.Target, end)
+}
 
-	if logFile == "" {
-		return
+// FmtFinding prints a finding followed by any listed bucket contents to w.
+func FmtFinding(w io.Writer, f Finding) {
+	FmtOutput(w, f.OutputData)
+	if f.DNS != nil && len(f.DNS.CNAMEs) > 0 {
+		fmt.Fprintf(w, "      CNAME: %s\n", strings.Join(f.DNS.CNAMEs, " -> "))
 	}
-	logMu.Lock()
-	defer logMu.Unlock()
-
-	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
+	if f.Files == nil {
 		return
 	}
-	defer f.Close()
-
-	switch logFormat {
-	case "text":
-		fmt.Fprintf(f, "%s: %s\n", data.Msg, data.Target)
-	case "csv":
-		w := csv.NewWriter(f)
-		_ = w.Write([]string{data.Platform, data.Msg, data.Target, data.Access})
-		w.Flush()
-	case "json":
-		_ = json.NewEncoder(f).Encode(data)
+	if len(f.Files) > 0 {
+		fmt.Fprintln(w, "      FILES:")
+		for _, file := range f.Files {
+			fmt.Fprintf(w, "      ->%s\n", file)
+		}
+	} else {
+		fmt.Fprintln(w, "      ...empty bucket, so sad. :(")
 	}
 }
 
@@ -178,17 +256,30 @@ func IsValidDomain(domain string) bool {
 	return true
 }
 
+// urlHost returns the hostname part of a URL or bare host[:port][/path].
+func urlHost(u string) string {
+	if _, rest, ok := strings.Cut(u, "://"); ok {
+		u = rest
+	}
+	if i := strings.IndexAny(u, "/?"); i >= 0 {
+		u = u[:i]
+	}
+	if host, _, err := net.SplitHostPort(u); err == nil {
+		return host
+	}
+	return u
+}
+
 // IsValidIP returns true when addr is a valid IPv4/IPv6 address.
 func IsValidIP(addr string) bool {
 	return net.ParseIP(addr) != nil
 }
 
 // ReadNameservers reads nameserver IPs from a file (one per line, # comments).
-func ReadNameservers(filePath string) []string {
+func ReadNameservers(filePath string) ([]string, error) {
 	f, err := os.Open(filePath)
 	if err != nil {
-		fmt.Printf("Error: File '%s' not found.\n", filePath)
-		os.Exit(1)
+		return nil, fmt.Errorf("nameserver file '%s' not found", filePath)
 	}
 	defer f.Close()
 
@@ -201,10 +292,9 @@ func ReadNameservers(filePath string) []string {
 		}
 	}
 	if len(ns) == 0 {
-		fmt.Println("Nameserver file is empty or only contains comments")
-		os.Exit(1)
+		return nil, errors.New("nameserver file is empty or only contains comments")
 	}
-	return ns
+	return ns, nil
 }
 
 // ---------------------------------------------------------------------------
@@ -220,27 +310,32 @@ func extractReason(status string) string {
 }
 
 // GetURLBatch sends HTTP GETs for every entry in urlList (prepending a
-// protocol) and passes each result to callback. The callback returns true
-// to abort the remaining work ("breakout").
+// protocol unless the entry is already a full URL) and passes each result
+// to callback. The callback returns true to abort the remaining work
+// ("breakout").
 //
 // Uses a persistent worker-pool so all goroutines stay busy; one slow
-// request no longer blocks the rest of the batch.
-func GetURLBatch(urlList []string, useSSL bool, callback func(*HttpResult) bool, threads int, followRedirects bool) {
+// request no longer blocks the rest of the batch. Throttled candidates are
+// re-queued with backoff while the provider's concurrency is lowered.
+// Once ctx is done no new requests start; in-flight ones are drained and
+// ctx.Err() is returned.
+func (s *Scanner) GetURLBatch(ctx context.Context, urlList []string, useSSL bool, callback func(*HttpResult) bool, followRedirects bool) error {
 	total := len(urlList)
 	if total == 0 {
-		return
+		return ctx.Err()
 	}
+	threads := s.cfg.Threads
 
 	// Filter out domains that are obviously invalid.
 	var valid []string
 	for _, u := range urlList {
-		if IsValidDomain(u) {
+		if IsValidDomain(urlHost(u)) {
 			valid = append(valid, u)
 		}
 	}
 	total = len(valid)
 	if total == 0 {
-		return
+		return ctx.Err()
 	}
 
 	proto := "http://"
@@ -248,80 +343,145 @@ func GetURLBatch(urlList []string, useSSL bool, callback func(*HttpResult) bool,
 		proto = "https://"
 	}
 
-	client := &http.Client{
-		Timeout: 15 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        threads * 4,
-			MaxIdleConnsPerHost: threads,
-			MaxConnsPerHost:     threads,
-			IdleConnTimeout:     30 * time.Second,
-			DisableKeepAlives:   false,
-			DialContext: (&net.Dialer{
-				Timeout:   5 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			TLSHandshakeTimeout:   5 * time.Second,
-			ResponseHeaderTimeout: 10 * time.Second,
-		},
-	}
-	if !followRedirects {
-		client.CheckRedirect = func(_ *http.Request, _ []*http.Request) error {
-			return http.ErrUseLastResponse
-		}
+	provider := s.provider
+	maxBody := s.cfg.MaxBodySize
+
+	// Candidates finished by a resumed run are skipped.
+	b := s.cp.beginBatch(s.current, valid)
+	start := 0
+	if b != nil {
+		start = b.start
+	}
+
+	type jobResult struct {
+		idx    int
+		result *HttpResult
 	}
 
 	// Worker pool: feed URLs into a channel, N workers pull from it.
-	jobs := make(chan string, threads*2)
-	resultsCh := make(chan *HttpResult, threads*2)
-	var done int64
+	jobs := make(chan urlJob, threads*2)
+	resultsCh := make(chan jobResult, threads*2)
+	done := int64(start)
 	var aborted int64 // set to 1 on breakout
 
+	limit := s.adaptive[provider]
+	if limit == nil {
+		limit = newAdaptiveLimit(threads)
+	}
+	retries := newRetryQueue()
+	var pending int64 // jobs handed out and neither finished nor re-queued
+
+	// finish accounts for a job that won't come back.
+	finish := func() {
+		atomic.AddInt64(&done, 1)
+		if atomic.AddInt64(&pending, -1) == 0 {
+			retries.wake()
+		}
+	}
+
 	var workerWg sync.WaitGroup
 	for w := 0; w < threads; w++ {
 		workerWg.Add(1)
 		go func() {
 			defer workerWg.Done()
-			for url := range jobs {
-				if atomic.LoadInt64(&aborted) != 0 {
-					atomic.AddInt64(&done, 1)
+			for j := range jobs {
+				url := j.url
+				fullURL := url
+				if !strings.Contains(url, "://") {
+					fullURL = proto + url
+				}
+				if atomic.LoadInt64(&aborted) != 0 ||
+					s.limiter.wait(ctx, s, provider, urlHost(fullURL)) != nil ||
+					limit.acquire(ctx) != nil {
+					finish()
 					continue // drain channel
 				}
-				rateLimitCheck()
-				fullURL := proto + url
-				resp, err := client.Get(fullURL)
+				s.stats.requests.Add(1)
+				result, err := s.fetch(ctx, fullURL, followRedirects, maxBody)
 				if err != nil {
-					if !strings.Contains(err.Error(), "context canceled") {
-						fmt.Printf("    [!] Connection error on %s: %v\n", url, err)
+					limit.release(false, 0)
+					if !errors.Is(err, context.Canceled) {
+						s.Printf("    [!] Connection error on %s: %v\n", url, err)
 					}
-					atomic.AddInt64(&done, 1)
+					b.markDone(j.idx)
+					finish()
 					continue
 				}
-				// Read only first 8 KB — we only need headers / short XML.
-				body := make([]byte, 8192)
-				n, _ := io.ReadAtLeast(resp.Body, body, 1)
-				resp.Body.Close()
-
-				resultsCh <- &HttpResult{
-					URL:         resp.Request.URL.String(),
-					StatusCode:  resp.StatusCode,
-					Reason:      extractReason(resp.Status),
-					Body:        string(body[:max(n, 0)]),
-					OriginalURL: fullURL,
+
+				throttled, retryAfter := isThrottled(result)
+				if lowered := limit.release(throttled, retryAfter); lowered > 0 {
+					s.Printf("\n    [*] Throttled by %s, lowering concurrency to %d\n", urlHost(fullURL), lowered)
 				}
-				atomic.AddInt64(&done, 1)
+				if throttled {
+					s.stats.throttled.Add(1)
+					if j.attempt < maxThrottleRetries {
+						s.stats.retried.Add(1)
+						j.attempt++
+						retries.push(j, time.Now().Add(retryDelay(j.attempt, retryAfter)))
+						atomic.AddInt64(&pending, -1)
+						continue
+					}
+					s.stats.gaveUp.Add(1)
+					s.Printf("    [!] Giving up on %s after %d throttled attempts\n", url, j.attempt+1)
+					b.markDone(j.idx)
+					finish()
+					continue
+				}
+
+				resultsCh <- jobResult{j.idx, result}
+				finish()
 			}
 		}()
 	}
 
-	// Feeder goroutine.
+	// Feeder goroutine: due retries first, then fresh candidates. Once
+	// those run out it waits for retries until no job is outstanding.
 	go func() {
-		for _, u := range valid {
-			if atomic.LoadInt64(&aborted) != 0 {
-				break
+		defer close(jobs)
+		send := func(j urlJob) bool {
+			atomic.AddInt64(&pending, 1)
+			select {
+			case jobs <- j:
+				return true
+			case <-ctx.Done():
+				atomic.AddInt64(&pending, -1)
+				return false
+			}
+		}
+		next := start
+		for atomic.LoadInt64(&aborted) == 0 {
+			j, ok, wait := retries.pop()
+			if !ok && next < len(valid) {
+				j, ok = urlJob{idx: next, url: valid[next]}, true
+				next++
+			}
+			if ok {
+				if !send(j) {
+					return
+				}
+				continue
+			}
+			if wait == 0 && atomic.LoadInt64(&pending) == 0 {
+				return // everything finished
+			}
+			var timer *time.Timer
+			var due <-chan time.Time
+			if wait > 0 {
+				timer = time.NewTimer(wait)
+				due = timer.C
+			}
+			select {
+			case <-retries.notify:
+			case <-due:
+			case <-ctx.Done():
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			if ctx.Err() != nil {
+				return
 			}
-			jobs <- u
 		}
-		close(jobs)
 	}()
 
 	// Closer: when all workers finish, close results.
@@ -330,50 +490,43 @@ func GetURLBatch(urlList []string, useSSL bool, callback func(*HttpResult) bool,
 		close(resultsCh)
 	}()
 
-	// Progress ticker.
-	ticker := time.NewTicker(500 * time.Millisecond)
-	defer ticker.Stop()
-	go func() {
-		for range ticker.C {
-			d := atomic.LoadInt64(&done)
-			fmt.Printf("\r    %d/%d complete...", d, total)
-		}
-	}()
+	stopProgress := s.progress(&done, total)
 
 	// Consume results.
 	for r := range resultsCh {
-		if callback(r) {
+		if callback(r.result) {
 			atomic.StoreInt64(&aborted, 1)
 		}
+		b.markDone(r.idx)
 	}
 
-	fmt.Printf("\r    %d/%d complete...\n", total, total)
-	fmt.Print("\r                            \r")
+	stopProgress()
+	return ctx.Err()
 }
 
-// ListBucketContents fetches an open bucket URL and prints the keys found.
-func ListBucketContents(bucket string) {
-	resp, err := http.Get(bucket)
-	if err != nil {
-		return
-	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-
-	keyRe := regexp.MustCompile(`<(?:Key|Name)>(.*?)</(?:Key|Name)>`)
-	matches := keyRe.FindAllStringSubmatch(string(body), -1)
-
-	// Strip query parameters before building full URLs.
-	subRe := regexp.MustCompile(`\?.*`)
-	bucket = subRe.ReplaceAllString(bucket, "")
-
-	if len(matches) > 0 {
-		fmt.Println("      FILES:")
-		for _, m := range matches {
-			fmt.Printf("      ->%s%s\n", bucket, m[1])
+// progress prints a "\r done/total complete..." ticker until the returned
+// function is called.
+func (s *Scanner) progress(done *int64, total int) func() {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case <-ticker.C:
+				s.Printf("\r    %d/%d complete...", atomic.LoadInt64(done), total)
+			case <-stop:
+				return
+			}
 		}
-	} else {
-		fmt.Println("      ...empty bucket, so sad. :(")
+	}()
+	return func() {
+		ticker.Stop()
+		close(stop)
+		<-finished
+		s.Printf("\r    %d/%d complete...\n", atomic.LoadInt64(done), total)
+		s.Printf("\r                            \r")
 	}
 }
 
@@ -386,88 +539,25 @@ func useCustomNS(nameserver, nameserverFile string) bool {
 	return nameserverFile != "" || (nameserver != "" && nameserver != "1.1.1.1")
 }
 
-// newResolver builds a net.Resolver that talks to the supplied nameservers
-// with short dial timeouts.
-func newResolver(nameservers []string) *net.Resolver {
-	var idx uint64
-	return &net.Resolver{
-		PreferGo: true,
-		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
-			i := atomic.AddUint64(&idx, 1) - 1
-			ns := nameservers[i%uint64(len(nameservers))]
-			d := net.Dialer{Timeout: 3 * time.Second}
-			return d.DialContext(ctx, "udp", ns+":53")
-		},
-	}
-}
-
-// dnsLookup resolves a single name. Returns the name when found, "" for
-// NXDOMAIN / timeout, or a sentinel for fatal errors.
-func dnsLookup(resolver *net.Resolver, name string, timeout time.Duration) string {
-	for tries := 0; tries < 2; tries++ {
-		ctx, cancel := context.WithTimeout(context.Background(), timeout)
-		_, err := resolver.LookupHost(ctx, name)
-		cancel()
-
-		if err == nil {
-			return name
-		}
-
-		// Unwrap to find *net.DNSError.
-		var dnsErr *net.DNSError
-		if e, ok := err.(*net.DNSError); ok {
-			dnsErr = e
-		} else if w, ok2 := err.(interface{ Unwrap() error }); ok2 {
-			if e2, ok3 := w.Unwrap().(*net.DNSError); ok3 {
-				dnsErr = e2
-			}
-		}
-		if dnsErr != nil {
-			if dnsErr.IsNotFound {
-				return ""
-			}
-			if dnsErr.IsTimeout {
-				continue
-			}
-			return "-#BREAKOUT_DNS_ERROR#-"
-		}
-		return "" // non-DNS error — skip
-	}
-	return ""
-}
-
 // FastDNSLookup resolves a list of names concurrently and returns those that
-// exist. An optional callback is invoked for each valid name.
+// exist. An optional callback is invoked with the answer for each valid name.
+// A nameserver refusing queries aborts the lookups with ErrNameserver;
+// SERVFAILs and timeouts only count as failed lookups.
 //
 // DNS over UDP is lightweight, so this uses threads×10 concurrent workers
-// (capped at 500) for much higher throughput than the HTTP pool.
-func FastDNSLookup(names []string, nameserver, nameserverFile string, callback func(string), threads int) []string {
+// (capped at 500) for much higher throughput than the HTTP pool. Once ctx
+// is done no new lookups start and ctx.Err() is returned along with the
+// names found so far.
+func (s *Scanner) FastDNSLookup(ctx context.Context, names []string, callback func(*DNSAnswer)) ([]string, error) {
 	total := len(names)
 	if total == 0 {
-		return nil
+		return nil, ctx.Err()
 	}
 
-	// Decide which resolver to use.
-	// Default: system DNS (fast, cached, works through corporate proxies).
-	// Custom: only when the user explicitly passes -ns (non-default) or -nsf.
-	var resolver *net.Resolver
-	var lookupTimeout time.Duration
-	if useCustomNS(nameserver, nameserverFile) {
-		var nsList []string
-		if nameserverFile != "" {
-			nsList = ReadNameservers(nameserverFile)
-		} else {
-			nsList = []string{nameserver}
-		}
-		resolver = newResolver(nsList)
-		lookupTimeout = 3 * time.Second
-		fmt.Printf("[*] Using custom nameserver(s) for DNS resolution\n")
-	} else {
-		resolver = net.DefaultResolver
-		lookupTimeout = 5 * time.Second
+	if s.customNS {
+		s.Printf("[*] Using custom nameserver(s) for DNS resolution\n")
 	}
-
-	fmt.Printf("[*] Brute-forcing a list of %d possible DNS names\n", total)
+	s.Printf("[*] Brute-forcing a list of %d possible DNS names\n", total)
 
 	// Filter out obviously invalid domains.
 	var filtered []string
@@ -478,11 +568,15 @@ func FastDNSLookup(names []string, nameserver, nameserverFile string, callback f
 	}
 	total = len(filtered)
 	if total == 0 {
-		return nil
+		return nil, ctx.Err()
+	}
+
+	if err := s.checkResolvers(ctx); err != nil {
+		return nil, err
 	}
 
 	// DNS is lightweight — use far more workers than HTTP.
-	dnsConcurrency := threads * 10
+	dnsConcurrency := s.cfg.Threads * 10
 	if dnsConcurrency > 500 {
 		dnsConcurrency = 500
 	}
@@ -490,22 +584,50 @@ func FastDNSLookup(names []string, nameserver, nameserverFile string, callback f
 		dnsConcurrency = total
 	}
 
+	// Answers matching what random names under the same zone resolve to
+	// are ignored.
+	zoneOf := batchZones(filtered)
+	wild := s.wildcards(ctx, zoneOf, dnsConcurrency)
+	var ignored int
+
+	// Candidates finished by a resumed run are skipped, and the names they
+	// resolved to are taken from the checkpoint.
+	b := s.cp.beginBatch(s.current, filtered)
+	start := 0
+	if b != nil {
+		start = b.start
+	}
+
+	type lookupResult struct {
+		idx int
+		ans *DNSAnswer
+		err error
+	}
+
 	var (
 		validNames []string
-		validMu    sync.Mutex
-		done       int64
+		done       = int64(start)
+		aborted    int64 // set to 1 on nameserver errors
+		lookupErr  error
 	)
+	found := make(map[string]bool)
+	for _, name := range b.found() {
+		found[name] = true
+	}
 
-	jobs := make(chan string, dnsConcurrency*2)
-	resultsCh := make(chan string, dnsConcurrency*2)
+	jobs := make(chan int, dnsConcurrency*2)
+	resultsCh := make(chan lookupResult, dnsConcurrency*2)
 
 	var workerWg sync.WaitGroup
 	for w := 0; w < dnsConcurrency; w++ {
 		workerWg.Add(1)
 		go func() {
 			defer workerWg.Done()
-			for name := range jobs {
-				resultsCh <- dnsLookup(resolver, name, lookupTimeout)
+			for i := range jobs {
+				if atomic.LoadInt64(&aborted) == 0 && ctx.Err() == nil {
+					ans, err := s.lookup(ctx, filtered[i])
+					resultsCh <- lookupResult{i, ans, err}
+				}
 				atomic.AddInt64(&done, 1)
 			}
 		}()
@@ -513,10 +635,17 @@ func FastDNSLookup(names []string, nameserver, nameserverFile string, callback f
 
 	// Feeder goroutine.
 	go func() {
-		for _, n := range filtered {
-			jobs <- n
+		defer close(jobs)
+		for i := start; i < len(filtered); i++ {
+			if atomic.LoadInt64(&aborted) != 0 {
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
 		}
-		close(jobs)
 	}()
 
 	// Closer.
@@ -525,38 +654,76 @@ func FastDNSLookup(names []string, nameserver, nameserverFile string, callback f
 		close(resultsCh)
 	}()
 
-	// Progress ticker.
-	ticker := time.NewTicker(500 * time.Millisecond)
-	defer ticker.Stop()
-	go func() {
-		for range ticker.C {
-			d := atomic.LoadInt64(&done)
-			fmt.Printf("\r    %d/%d complete...", d, total)
-		}
-	}()
+	stopProgress := s.progress(&done, total)
 
 	// Consume results.
-	for name := range resultsCh {
-		if name == "" {
+	for r := range resultsCh {
+		if errors.Is(r.err, ErrNameserver) {
+			if lookupErr == nil {
+				lookupErr = r.err
+				atomic.StoreInt64(&aborted, 1)
+			}
 			continue
 		}
-		if name == "-#BREAKOUT_DNS_ERROR#-" {
-			fmt.Println("\n    [!] Error querying nameservers! This could be a problem.")
-			fmt.Println("    [!] If you're using a VPN, try setting -ns to your VPN's nameserver.")
-			os.Exit(1)
+		switch r.ans.Rcode {
+		case "REFUSED", "NOTIMP":
+			if lookupErr == nil {
+				lookupErr = fmt.Errorf("%w: %s answered %s for %s", ErrNameserver, r.ans.Server, r.ans.Rcode, r.ans.Name)
+				atomic.StoreInt64(&aborted, 1)
+			}
+			continue
+		case "NOERROR", "NXDOMAIN":
+		default:
+			if s.stats.lookupFailures.Add(1) == 1 && r.err != nil {
+				s.Printf("    [!] Lookup of %s failed: %v\n", r.ans.Name, r.err)
+			}
 		}
-		if callback != nil {
-			callback(name)
+		if r.ans.Found() && wild[zoneOf[filtered[r.idx]]].matches(r.ans) {
+			ignored++
+		} else if r.ans.Found() {
+			name := filtered[r.idx]
+			if callback != nil && !found[name] {
+				callback(r.ans)
+			}
+			found[name] = true
+			b.addFound(name)
 		}
-		validMu.Lock()
-		validNames = append(validNames, name)
-		validMu.Unlock()
+		b.markDone(r.idx)
 	}
 
-	fmt.Printf("\r    %d/%d complete...\n", total, total)
-	fmt.Print("\r                            \r")
+	stopProgress()
+	if ignored > 0 {
+		s.Printf("    [*] Ignored %d answers matching a wildcard\n", ignored)
+	}
 
-	return validNames
+	// Keep the input order so the result doesn't depend on timing.
+	for _, name := range filtered {
+		if found[name] {
+			validNames = append(validNames, name)
+			delete(found, name)
+		}
+	}
+
+	if lookupErr == nil {
+		lookupErr = ctx.Err()
+	}
+	return validNames, lookupErr
+}
+
+// lookup resolves name through the answer cache. Like HTTP requests,
+// queries already sent are drained rather than cancelled with ctx, but
+// no further ones are sent.
+func (s *Scanner) lookup(ctx context.Context, name string) (*DNSAnswer, error) {
+	if ans, ok := s.cache.get(s.resolverID, name); ok {
+		s.stats.cacheHits.Add(1)
+		return ans, nil
+	}
+	s.stats.lookups.Add(1)
+	ans, err := s.dns.resolve(ctx, name)
+	if err == nil {
+		s.cache.put(s.resolverID, ans)
+	}
+	return ans, err
 }
 
 // ---------------------------------------------------------------------------
@@ -597,13 +764,13 @@ func StartTimer() time.Time {
 	return time.Now()
 }
 
-// StopTimer prints elapsed time since start.
-func StopTimer(start time.Time) {
+// stopTimer prints elapsed time since start.
+func (s *Scanner) stopTimer(start time.Time) {
 	elapsed := time.Since(start)
 	h := int(elapsed.Hours())
 	m := int(elapsed.Minutes()) % 60
-	s := int(elapsed.Seconds()) % 60
-	fmt.Println()
-	fmt.Printf(" Elapsed time: %02d:%02d:%02d\n", h, m, s)
-	fmt.Println()
+	sec := int(elapsed.Seconds()) % 60
+	s.Printf("\n")
+	s.Printf(" Elapsed time: %02d:%02d:%02d\n", h, m, sec)
+	s.Printf("\n")
 }
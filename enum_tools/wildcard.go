@@ -0,0 +1,163 @@
+package enum_tools
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ---------------------------------------------------------------------------
+// Wildcard / sinkhole detection
+// ---------------------------------------------------------------------------
+
+// Before brute-forcing names under a zone, a few random labels are resolved
+// there. A zone that answers for them has a wildcard record, or the resolver
+// rewrites NXDOMAIN to a sinkhole; either way, candidates answering the
+// same thing don't exist.
+
+// wildcardProbes is how many random names are resolved per zone.
+const wildcardProbes = 3
+
+// wildcard is the answer set a zone returned for random names. A nil
+// *wildcard matches nothing.
+type wildcard struct {
+	ips     map[string]bool
+	targets map[string]bool // ends of CNAME chains
+}
+
+// matches reports whether ans is indistinguishable from the wildcard: it
+// ends at one of its CNAME targets or, without CNAMEs, only has its IPs.
+func (w *wildcard) matches(ans *DNSAnswer) bool {
+	if w == nil || len(ans.IPs) == 0 {
+		return false
+	}
+	if len(ans.CNAMEs) > 0 {
+		return w.targets[ans.Target()]
+	}
+	for _, ip := range ans.IPs {
+		if !w.ips[ip] {
+			return false
+		}
+	}
+	return true
+}
+
+// String lists the wildcard's answers for the warning.
+func (w *wildcard) String() string {
+	var answers []string
+	for t := range w.targets {
+		answers = append(answers, t)
+	}
+	for ip := range w.ips {
+		answers = append(answers, ip)
+	}
+	sort.Strings(answers)
+	return strings.Join(answers, ", ")
+}
+
+// parentZone returns the zone directly above name.
+func parentZone(name string) string {
+	_, zone, _ := strings.Cut(name, ".")
+	return zone
+}
+
+// batchZones maps each name to the zone probed for it: the highest parent
+// of any name of the batch above it. A wildcard also covers deeper names,
+// so mutations containing dots don't need probes of their own.
+func batchZones(names []string) map[string]string {
+	parents := make(map[string]bool)
+	for _, name := range names {
+		parents[parentZone(name)] = true
+	}
+	zoneOf := make(map[string]string, len(names))
+	for _, name := range names {
+		zone := parentZone(name)
+		for p := parentZone(zone); p != ""; p = parentZone(p) {
+			if parents[p] {
+				zone = p
+			}
+		}
+		zoneOf[name] = zone
+	}
+	return zoneOf
+}
+
+// randomLabel returns a label no zone should have a record for.
+func randomLabel() string {
+	const chars = "abcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, 20)
+	for i := range b {
+		b[i] = chars[rand.Intn(len(chars))]
+	}
+	return string(b)
+}
+
+// probeZone resolves random names under zone and returns what they
+// answered, or nil when they didn't resolve.
+func (s *Scanner) probeZone(ctx context.Context, zone string) *wildcard {
+	var w *wildcard
+	for i := 0; i < wildcardProbes; i++ {
+		s.stats.lookups.Add(1)
+		ans, err := s.dns.resolve(ctx, randomLabel()+"."+zone)
+		if err != nil || !ans.Found() {
+			continue
+		}
+		if w == nil {
+			w = &wildcard{ips: make(map[string]bool), targets: make(map[string]bool)}
+		}
+		for _, ip := range ans.IPs {
+			w.ips[ip] = true
+		}
+		if len(ans.CNAMEs) > 0 {
+			w.targets[ans.Target()] = true
+		}
+	}
+	return w
+}
+
+// wildcards returns the wildcard of each zone of zoneOf, probing the zones
+// not seen before by this scanner with up to workers lookups at once.
+func (s *Scanner) wildcards(ctx context.Context, zoneOf map[string]string, workers int) map[string]*wildcard {
+	s.zoneMu.Lock()
+	var todo []string
+	for _, zone := range zoneOf {
+		if _, seen := s.zones[zone]; !seen && zone != "" {
+			s.zones[zone] = nil
+			todo = append(todo, zone)
+		}
+	}
+	s.zoneMu.Unlock()
+	sort.Strings(todo)
+
+	sem := make(chan struct{}, max(1, workers))
+	var wg sync.WaitGroup
+	for _, zone := range todo {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(zone string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			w := s.probeZone(ctx, zone)
+			if w == nil {
+				return
+			}
+			s.Printf("    [!] %s answers for random names (%s): a wildcard or a lying resolver. Matching results are ignored.\n", zone, w)
+			s.zoneMu.Lock()
+			s.zones[zone] = w
+			s.zoneMu.Unlock()
+		}(zone)
+	}
+	wg.Wait()
+
+	s.zoneMu.Lock()
+	defer s.zoneMu.Unlock()
+	out := make(map[string]*wildcard)
+	for _, zone := range zoneOf {
+		if w := s.zones[zone]; w != nil {
+			out[zone] = w
+		}
+	}
+	return out
+}
@@ -2,12 +2,19 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"embed"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
-	"regexp"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/BatVogt/impatient_cloud_enum/enum_tools"
@@ -16,6 +23,9 @@ import (
 //go:embed enum_tools/fuzz.txt
 var fuzzFS embed.FS
 
+//go:embed scenarios/demo.json
+var demoScenario []byte
+
 const banner = `
 ##########################
     impatient_cloud_enum
@@ -24,6 +34,17 @@ const banner = `
 
 `
 
+// Exit statuses.
+const (
+	exitInterrupted = 130 // first Ctrl-C: scan stopped gracefully
+	exitForceQuit   = 131 // second Ctrl-C: stopped without draining
+)
+
+// console receives everything meant to be read by a person: the banner,
+// progress, messages and the coloured findings. It is stderr when the
+// findings are written to stdout, which then carries nothing else.
+var console io.Writer = os.Stdout
+
 // ---------------------------------------------------------------------------
 // Flag helpers
 // ---------------------------------------------------------------------------
@@ -37,12 +58,27 @@ func (s *stringSlice) Set(v string) error {
 	return nil
 }
 
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
 // ---------------------------------------------------------------------------
 // Argument parsing
 // ---------------------------------------------------------------------------
 
 type cliArgs struct {
 	keywords       []string
+	subdomains     []string
+	assets         []string
+	ipRanges       *enum_tools.IPRanges
+	rules          *enum_tools.Rules
 	mutationsFile  string
 	bruteFile      string
 	threads        int
@@ -50,12 +86,40 @@ type cliArgs struct {
 	nameserverFile string
 	logfile        string
 	logFormat      string
+	outputs        []string // -o specs, format:path
+	report         string
+	summary        string
 	disableAWS     bool
 	disableAzure   bool
 	disableGCP     bool
 	quickScan      bool
+	checks         []string
+	skipChecks     []string
+	stateFile      string
+	dnsCacheFile   string
+	authoritative  bool
+	authQPS        float64
 	rateLimitReqs  int
 	rateLimitSleep int
+	endpoints      map[string]enum_tools.Endpoint
+	rateLimit      enum_tools.RateLimit
+	providerLimits map[string]enum_tools.RateLimit
+	hostLimits     map[string]enum_tools.RateLimit
+	dnsOverride    string
+	proxy          string
+	proxyDNS       bool
+	timeout        time.Duration
+	retries        int
+	insecure       bool
+	userAgent      string
+	headers        http.Header
+	maxBody        int64
+}
+
+// output is a destination of the findings.
+type output struct {
+	sink   enum_tools.Sink
+	stream bool // stdout, read as the scan runs
 }
 
 func parseArguments() *cliArgs {
@@ -63,134 +127,281 @@ func parseArguments() *cliArgs {
 
 	var keywords stringSlice
 	var keyfile string
+	var takeoverFile, attributeFile string
+	var rangeFiles stringSlice
+	var rulesFile string
+	var templatesDir string
+	var checks, skipChecks string
+	var listChecks bool
+	var endpoints stringSlice
+	var rps float64
+	var burst int
+	var rateLimits stringSlice
+	var headers stringSlice
+	var jsonl bool
+	var outputs stringSlice
 
 	flag.Var(&keywords, "k", "Keyword. Can use flag multiple times.")
 	flag.StringVar(&keyfile, "kf", "", "Input file with a single keyword per line.")
+	flag.StringVar(&takeoverFile, "takeover", "", "Check the target's own subdomains (file, one per line, or - for stdin) for dangling CNAMEs instead of scanning keywords.")
+	flag.StringVar(&attributeFile, "attribute", "", "Attribute the target's known hostnames / IPs (file, one per line, or - for stdin) to providers and services instead of scanning keywords.")
+	flag.Var(&rangeFiles, "ip-ranges", "Provider IP ranges for -attribute: AWS ip-ranges.json, Azure service tags or Google cloud.json. Can use flag multiple times.")
 	flag.StringVar(&args.mutationsFile, "m", "", "Mutations file (default: embedded fuzz.txt).")
 	flag.StringVar(&args.bruteFile, "b", "", "Brute-force list for Azure containers (default: embedded fuzz.txt).")
 	flag.IntVar(&args.threads, "t", 25, "Concurrent workers for HTTP/DNS brute-force. Default = 25.")
-	flag.StringVar(&args.nameserver, "ns", "1.1.1.1", "DNS server for brute-force. Default: system DNS (pass a custom IP to override).")
-	flag.StringVar(&args.nameserverFile, "nsf", "", "Path to file containing nameserver IPs.")
+	flag.StringVar(&args.nameserver, "ns", "1.1.1.1", "DNS server for brute-force: IP[:port], tls://host[:port] or https://host/dns-query. Default: system DNS (pass a custom one to override).")
+	flag.StringVar(&args.nameserverFile, "nsf", "", "Path to file containing nameservers, one per line, in any -ns form.")
 	flag.StringVar(&args.logfile, "l", "", "Appends found items to specified file.")
 	flag.StringVar(&args.logFormat, "f", "text", "Format for log file (text, json, csv). Default: text.")
+	flag.StringVar(&args.report, "report", "", "Write a self-contained HTML report of the findings to this file when the scan ends.")
+	flag.StringVar(&args.summary, "summary", "", "Write a Markdown summary of the findings to this file when the scan ends (plain text if it ends in .txt).")
+	flag.BoolVar(&jsonl, "jsonl", false, "Write each finding as a JSON line to stdout as it is found; everything else goes to stderr. Same as -o json:-.")
+	flag.Var(&outputs, "o", "Write findings to format:path (text, json or csv; path - is stdout). Can use flag multiple times.")
 	flag.BoolVar(&args.disableAWS, "disable-aws", false, "Disable Amazon checks.")
 	flag.BoolVar(&args.disableAzure, "disable-azure", false, "Disable Azure checks.")
 	flag.BoolVar(&args.disableGCP, "disable-gcp", false, "Disable Google checks.")
 	flag.BoolVar(&args.quickScan, "qs", false, "Disable all mutations and second-level scans.")
-	flag.IntVar(&args.rateLimitReqs, "rl", 8000, "Sleep after this many HTTP requests (0 = disabled). Default 8000.")
+	flag.StringVar(&checks, "checks", "", "Comma-separated checks or providers to run (default: all). See -list-checks.")
+	flag.StringVar(&skipChecks, "skip", "", "Comma-separated checks or providers to skip.")
+	flag.BoolVar(&listChecks, "list-checks", false, "List available checks and exit.")
+	flag.StringVar(&templatesDir, "templates", "", "Directory of JSON check templates to run alongside the built-in checks.")
+	flag.BoolVar(&args.authoritative, "authoritative", false, "Ask each service zone's authoritative nameservers directly instead of the resolvers.")
+	flag.Float64Var(&args.authQPS, "auth-qps", 20, "Queries per second per authoritative nameserver under -authoritative.")
+	flag.StringVar(&rulesFile, "rules", "", "JSON file of response rules tried before the built-in ones, to fix or extend classification.")
+	flag.StringVar(&args.dnsCacheFile, "dns-cache", "", "File to keep DNS answers in between runs (honouring their TTL).")
+	flag.StringVar(&args.stateFile, "resume", "", "State file to checkpoint progress to, and resume from if it exists.")
+	flag.IntVar(&args.rateLimitReqs, "rl", 8000, "Sleep after this many HTTP requests (0 = disabled). Default 8000. Off when -rps / -rate-limit are used, unless set.")
 	flag.IntVar(&args.rateLimitSleep, "rls", 240, "Seconds to sleep when rate limit is hit (default 240).")
+	flag.Float64Var(&rps, "rps", 0, "Global HTTP requests per second (0 = unlimited).")
+	flag.IntVar(&burst, "burst", 0, "Requests allowed back to back under -rps (default: one second's worth).")
+	flag.Var(&rateLimits, "rate-limit", "Budget for a provider or host suffix as target=rps[:burst], e.g. aws=50 or s3.amazonaws.com=20:5. Can use flag multiple times.")
+	flag.Var(&endpoints, "endpoint", "Override a service endpoint as service=URL, e.g. s3=http://{name}.s3.lab:9000. Can use flag multiple times.")
+	flag.StringVar(&args.proxy, "proxy", "", "Send all HTTP traffic through a proxy: http://, https:// or socks5://[user:pass@]host:port.")
+	flag.BoolVar(&args.proxyDNS, "proxy-dns", false, "Also send DNS queries (over TCP) through the socks5 -proxy.")
+	flag.DurationVar(&args.timeout, "timeout", 15*time.Second, "Timeout for each HTTP request, including the body.")
+	flag.IntVar(&args.retries, "retries", 2, "Retries on transient connection errors (timeouts, resets).")
+	flag.BoolVar(&args.insecure, "insecure", false, "Don't verify TLS certificates.")
+	flag.StringVar(&args.userAgent, "user-agent", "", "User-Agent header for HTTP requests (default: Go's).")
+	flag.Var(&headers, "H", "Extra HTTP header as 'Name: value'. Can use flag multiple times.")
+	flag.Int64Var(&args.maxBody, "max-body", 8192, "Bytes of each HTTP response body read.")
+	flag.StringVar(&args.dnsOverride, "dns-override", "", "Resolver (host:port) used for every DNS lookup and HTTP connection, e.g. a local stand-in.")
 
 	flag.Parse()
 
+	// -l / -f and -jsonl are shorthands for outputs.
+	if args.logfile != "" {
+		outputs = append(outputs, args.logFormat+":"+args.logfile)
+	}
+	if jsonl {
+		outputs = append(outputs, "json:-")
+	}
+	args.outputs = outputs
+
+	// When findings go to stdout it carries nothing else: every message,
+	// including the coloured findings, is printed to stderr instead.
+	for _, spec := range outputs {
+		if spec == "-" || strings.HasSuffix(spec, ":-") {
+			console = os.Stderr
+		}
+	}
+
+	// Templates register checks, so they're loaded before anything
+	// selects or lists them.
+	if templatesDir != "" {
+		templates, err := enum_tools.LoadTemplates(templatesDir)
+		if err != nil {
+			fmt.Fprintf(console, "[!] %v\n", err)
+			os.Exit(1)
+		}
+		for _, c := range templates {
+			enum_tools.Register(c)
+		}
+	}
+
+	if listChecks {
+		fmt.Fprint(console, enum_tools.ListChecks())
+		os.Exit(0)
+	}
+
+	// -takeover and -attribute work on the target's own names instead of
+	// keywords.
+	if takeoverFile != "" && attributeFile != "" {
+		fmt.Fprintln(console, "[!] Use either -takeover or -attribute, not both")
+		os.Exit(1)
+	}
+	if (takeoverFile != "" || attributeFile != "") && (len(keywords) > 0 || keyfile != "") {
+		fmt.Fprintln(console, "[!] -takeover and -attribute can't be combined with -k or -kf")
+		os.Exit(1)
+	}
+	if takeoverFile != "" {
+		args.subdomains = readTargets(takeoverFile, "Subdomain")
+	}
+	if attributeFile != "" {
+		args.assets = readTargets(attributeFile, "Asset")
+	}
+	if len(rangeFiles) > 0 {
+		args.ipRanges = enum_tools.NewIPRanges()
+		for _, path := range rangeFiles {
+			if err := args.ipRanges.Load(path); err != nil {
+				fmt.Fprintf(console, "[!] %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+
 	// Must supply either -k or -kf.
-	if len(keywords) == 0 && keyfile == "" {
-		fmt.Println("[!] You must provide keywords via -k or a keyword file via -kf")
+	if len(keywords) == 0 && keyfile == "" && takeoverFile == "" && attributeFile == "" {
+		fmt.Fprintln(console, "[!] You must provide keywords via -k or a keyword file via -kf (or names via -takeover / -attribute)")
 		flag.Usage()
 		os.Exit(1)
 	}
 	if len(keywords) > 0 && keyfile != "" {
-		fmt.Println("[!] Use either -k or -kf, not both")
+		fmt.Fprintln(console, "[!] Use either -k or -kf, not both")
 		os.Exit(1)
 	}
 
 	// Read keywords from file if needed.
 	if keyfile != "" {
-		f, err := os.Open(keyfile)
-		if err != nil {
-			fmt.Printf("[!] Cannot access keyword file: %v\n", err)
+		var err error
+		if keywords, err = readList(keyfile); err != nil {
+			fmt.Fprintf(console, "[!] Cannot access keyword file: %v\n", err)
 			os.Exit(1)
 		}
-		defer f.Close()
-		scanner := bufio.NewScanner(f)
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
-			if line != "" {
-				keywords = append(keywords, line)
-			}
-		}
 		if len(keywords) == 0 {
-			fmt.Println("[!] Keyword file is empty")
+			fmt.Fprintln(console, "[!] Keyword file is empty")
 			os.Exit(1)
 		}
 	}
 	args.keywords = keywords
 
+	for _, spec := range endpoints {
+		service, ep, err := enum_tools.ParseEndpoint(spec)
+		if err != nil {
+			fmt.Fprintf(console, "[!] %v\n", err)
+			os.Exit(1)
+		}
+		if args.endpoints == nil {
+			args.endpoints = make(map[string]enum_tools.Endpoint)
+		}
+		args.endpoints[service] = ep
+	}
+
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			fmt.Fprintf(console, "[!] Header %q must be 'Name: value'\n", h)
+			os.Exit(1)
+		}
+		if args.headers == nil {
+			args.headers = make(http.Header)
+		}
+		args.headers.Add(name, strings.TrimSpace(value))
+	}
+
+	if rulesFile != "" {
+		var err error
+		if args.rules, err = enum_tools.LoadRules(rulesFile); err != nil {
+			fmt.Fprintf(console, "[!] %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Token-bucket limits replace the -rl / -rls pause unless it was asked
+	// for explicitly.
+	if rps > 0 {
+		args.rateLimit = enum_tools.RateLimit{RPS: rps, Burst: burst}
+	}
+	for _, spec := range rateLimits {
+		target, rl, err := enum_tools.ParseRateLimit(spec)
+		if err != nil {
+			fmt.Fprintf(console, "[!] %v\n", err)
+			os.Exit(1)
+		}
+		if strings.Contains(target, ".") {
+			if args.hostLimits == nil {
+				args.hostLimits = make(map[string]enum_tools.RateLimit)
+			}
+			args.hostLimits[target] = rl
+		} else {
+			if args.providerLimits == nil {
+				args.providerLimits = make(map[string]enum_tools.RateLimit)
+			}
+			args.providerLimits[target] = rl
+		}
+	}
+	if rps > 0 || len(rateLimits) > 0 {
+		rlSet := false
+		flag.Visit(func(f *flag.Flag) { rlSet = rlSet || f.Name == "rl" })
+		if !rlSet {
+			args.rateLimitReqs = 0
+		}
+	}
+
+	args.checks = splitList(checks)
+	args.skipChecks = splitList(skipChecks)
+	if args.disableAWS {
+		args.skipChecks = append(args.skipChecks, "aws")
+	}
+	if args.disableAzure {
+		args.skipChecks = append(args.skipChecks, "azure")
+	}
+	if args.disableGCP {
+		args.skipChecks = append(args.skipChecks, "gcp")
+	}
+
 	// Validate mutations file.
 	if args.mutationsFile != "" {
 		if _, err := os.Stat(args.mutationsFile); err != nil {
-			fmt.Printf("[!] Cannot access mutations file: %s\n", args.mutationsFile)
+			fmt.Fprintf(console, "[!] Cannot access mutations file: %s\n", args.mutationsFile)
 			os.Exit(1)
 		}
 	}
 	// Validate brute file.
 	if args.bruteFile != "" {
 		if _, err := os.Stat(args.bruteFile); err != nil {
-			fmt.Println("[!] Cannot access brute-force file, exiting")
+			fmt.Fprintln(console, "[!] Cannot access brute-force file, exiting")
 			os.Exit(1)
 		}
 	}
 
-	// Validate log file.
-	if args.logfile != "" {
-		info, err := os.Stat(args.logfile)
-		if err == nil && info.IsDir() {
-			fmt.Println("[!] Can't specify a directory as the logfile, exiting.")
+	// Validate outputs.
+	toStdout := 0
+	for _, spec := range args.outputs {
+		_, path, err := enum_tools.ParseSinkSpec(spec)
+		if err != nil {
+			fmt.Fprintf(console, "[!] %v\n", err)
 			os.Exit(1)
 		}
-		// Verify format.
-		switch args.logFormat {
-		case "text", "json", "csv":
-		default:
-			fmt.Println("[!] Sorry! Allowed log formats: 'text', 'json', or 'csv'")
+		if path == "-" {
+			toStdout++
+			continue
+		}
+		info, err := os.Stat(path)
+		if err == nil && info.IsDir() {
+			fmt.Fprintf(console, "[!] Can't write findings to a directory (%s), exiting.\n", path)
 			os.Exit(1)
 		}
-		enum_tools.InitLogfile(args.logfile, args.logFormat)
+	}
+	if toStdout > 1 {
+		fmt.Fprintln(console, "[!] Only one output can write to stdout")
+		os.Exit(1)
 	}
 
 	return args
 }
 
 // ---------------------------------------------------------------------------
-// Name building
+// Input files
 // ---------------------------------------------------------------------------
 
-func cleanText(text string) string {
-	banned := regexp.MustCompile(`[^a-z0-9.\-]`)
-	return banned.ReplaceAllString(strings.ToLower(text), "")
-}
-
-func appendName(name string, names *[]string) {
-	if len(name) <= 63 {
-		*names = append(*names, name)
-	}
-}
-
-func buildNames(baseList, mutations []string) []string {
-	var names []string
-	for _, base := range baseList {
-		base = cleanText(base)
-		appendName(base, &names)
-		for _, mut := range mutations {
-			mut = cleanText(mut)
-			appendName(base+mut, &names)
-			appendName(base+"."+mut, &names)
-			appendName(base+"-"+mut, &names)
-			appendName(mut+base, &names)
-			appendName(mut+"."+base, &names)
-			appendName(mut+"-"+base, &names)
-		}
-	}
-	fmt.Printf("[+] Mutated results: %d items\n", len(names))
-	return names
-}
-
 // readFileOrEmbedded returns the content of a user-supplied file, or falls
 // back to the embedded fuzz.txt.
 func readFileOrEmbedded(path string) string {
 	if path != "" {
 		data, err := os.ReadFile(path)
 		if err != nil {
-			fmt.Printf("[!] Cannot read file %s: %v\n", path, err)
+			fmt.Fprintf(console, "[!] Cannot read file %s: %v\n", path, err)
 			os.Exit(1)
 		}
 		return string(data)
@@ -199,6 +410,42 @@ func readFileOrEmbedded(path string) string {
 	return string(data)
 }
 
+// readList returns the non-empty lines of a file, or of stdin for "-".
+func readList(path string) ([]string, error) {
+	in := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+	var out []string
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out, scanner.Err()
+}
+
+// readTargets reads a -takeover / -attribute list, exiting when it's
+// unreadable or empty.
+func readTargets(path, what string) []string {
+	list, err := readList(path)
+	if err != nil {
+		fmt.Fprintf(console, "[!] Cannot read %s list: %v\n", strings.ToLower(what), err)
+		os.Exit(1)
+	}
+	if len(list) == 0 {
+		fmt.Fprintf(console, "[!] %s list is empty\n", what)
+		os.Exit(1)
+	}
+	return list
+}
+
 func readMutations(path string) []string {
 	raw := readFileOrEmbedded(path)
 	lines := strings.Split(raw, "\n")
@@ -209,69 +456,457 @@ func readMutations(path string) []string {
 			out = append(out, l)
 		}
 	}
-	fmt.Printf("[+] Mutations list imported: %d items\n", len(out))
 	return out
 }
 
+// ---------------------------------------------------------------------------
+// Signal handling
+// ---------------------------------------------------------------------------
+
+// interruptContext returns a context that is cancelled on the first
+// SIGINT / SIGTERM so the scan can drain and report what it found. A second
+// signal force-quits immediately, after calling beforeExit (if not nil).
+func interruptContext(beforeExit func()) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-sigs:
+		case <-ctx.Done():
+			return
+		}
+		fmt.Fprintln(console, "\n[!] Interrupted, finishing in-flight requests. Press Ctrl-C again to force quit.")
+		cancel()
+		<-sigs
+		fmt.Fprintln(console, "\n[!] Forced quit.")
+		if beforeExit != nil {
+			beforeExit()
+		}
+		os.Exit(exitForceQuit)
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigs)
+		cancel()
+	}
+}
+
+// printSummary lists the findings of an interrupted scan.
+func printSummary(findings []enum_tools.Finding) {
+	fmt.Fprintf(console, "\n[+] Found %d item(s) before the scan was interrupted:\n", len(findings))
+	for _, f := range findings {
+		enum_tools.FmtOutput(console, f.OutputData)
+	}
+}
+
+// printStats reports how the scan's HTTP requests fared.
+func printStats(scanner *enum_tools.Scanner) {
+	st := scanner.Stats()
+	fmt.Fprintf(console, "[*] HTTP requests: %d sent, %d throttled, %d retried", st.Requests, st.Throttled, st.Retried)
+	if st.GaveUp > 0 {
+		fmt.Fprintf(console, ", %d given up", st.GaveUp)
+	}
+	fmt.Fprintln(console)
+	if st.Lookups > 0 || st.CacheHits > 0 {
+		fmt.Fprintf(console, "[*] DNS lookups: %d, %d failed (SERVFAIL / timeout)", st.Lookups, st.LookupFailures)
+		if st.CacheHits > 0 {
+			fmt.Fprintf(console, ", %d more answered from cache", st.CacheHits)
+		}
+		fmt.Fprintln(console)
+		for _, rs := range scanner.ResolverStats() {
+			fmt.Fprintf(console, "    %s: %d queries, %d errors, %v average", rs.Server, rs.Queries, rs.Errors, rs.Latency.Round(time.Microsecond))
+			if rs.Evicted != "" {
+				fmt.Fprintf(console, ", dropped (%s)", rs.Evicted)
+			}
+			fmt.Fprintln(console)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Simulator
+// ---------------------------------------------------------------------------
+
+// runSimulate implements the "simulate" subcommand: it serves a scenario
+// over local HTTP and DNS until interrupted, and prints the flags that
+// point a scan at it.
+func runSimulate(argv []string) {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	scenarioFile := fs.String("scenario", "", "Scenario file (default: built-in demo for the keyword 'acme').")
+	httpAddr := fs.String("http", "127.0.0.1:8080", "Address for the HTTP server.")
+	dnsAddr := fs.String("dns", "127.0.0.1:5353", "Address for the UDP DNS server.")
+	quiet := fs.Bool("q", false, "Don't log requests.")
+	listPresets := fs.Bool("list-presets", false, "List the response presets and exit.")
+	fs.Parse(argv)
+
+	if *listPresets {
+		for _, p := range enum_tools.SimPresets() {
+			fmt.Fprintf(console, "  %s\n", p)
+		}
+		return
+	}
+
+	var sc *enum_tools.Scenario
+	var err error
+	if *scenarioFile != "" {
+		sc, err = enum_tools.LoadScenario(*scenarioFile)
+	} else {
+		sc, err = enum_tools.ParseScenario(demoScenario)
+	}
+	if err != nil {
+		fmt.Fprintf(console, "[!] %v\n", err)
+		os.Exit(1)
+	}
+
+	sim := enum_tools.NewSimulator(sc)
+	if !*quiet {
+		sim.Log = os.Stdout
+	}
+	if err := sim.Start(*httpAddr, *dnsAddr); err != nil {
+		fmt.Fprintf(console, "[!] %v\n", err)
+		os.Exit(1)
+	}
+	defer sim.Close()
+
+	fmt.Fprintf(console, "[+] Simulating %d resources: HTTP on %s, DNS on %s\n", len(sc.Resources), sim.HTTPAddr(), sim.DNSAddr())
+	fmt.Fprintln(console, "[*] Point a scan at it with:")
+	endpoints := sim.Endpoints()
+	flags := []string{"-rl 0", "-dns-override " + sim.DNSAddr()}
+	for _, service := range enum_tools.EndpointServices() {
+		e := endpoints[service]
+		path := ""
+		if e.PathStyle {
+			path = "/{name}"
+		}
+		flags = append(flags, "-endpoint "+service+"="+e.URLForHost(e.Host, path, "http"))
+	}
+	fmt.Fprintf(console, "    %s \\\n      %s\n", os.Args[0], strings.Join(flags, " \\\n      "))
+
+	ctx, stop := interruptContext(nil)
+	defer stop()
+	<-ctx.Done()
+	fmt.Fprintln(console, "[+] Simulator stopped")
+}
+
+// ---------------------------------------------------------------------------
+// Reports
+// ---------------------------------------------------------------------------
+
+// writeReports writes the -report and -summary files of a finished scan.
+func writeReports(args *cliArgs, info enum_tools.ReportInfo, findings []enum_tools.Finding) {
+	if args.report != "" {
+		if err := enum_tools.WriteHTMLReport(args.report, info, findings); err != nil {
+			fmt.Fprintf(console, "    [!] Could not write the report: %v\n", err)
+		} else {
+			fmt.Fprintf(console, "[+] Report written to %s\n", args.report)
+		}
+	}
+	if args.summary != "" {
+		var b bytes.Buffer
+		enum_tools.WriteSummary(&b, !strings.HasSuffix(args.summary, ".txt"), info, findings)
+		if err := os.WriteFile(args.summary, b.Bytes(), 0644); err != nil {
+			fmt.Fprintf(console, "    [!] Could not write the summary: %v\n", err)
+		} else {
+			fmt.Fprintf(console, "[+] Summary written to %s\n", args.summary)
+		}
+	}
+}
+
+// runReport implements the "report" subcommand: it builds a summary or
+// HTML report from the findings of an existing json log.
+func runReport(argv []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	format := fs.String("format", "markdown", "Report format: markdown, text or html.")
+	out := fs.String("o", "", "Output file (default: stdout; required for html).")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s report [-format markdown|text|html] [-o file] log.json\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(argv)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(console, "[!] %v\n", err)
+		os.Exit(1)
+	}
+	findings, err := enum_tools.ReadJSONLog(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(console, "[!] %s: %v (is it a json log?)\n", fs.Arg(0), err)
+		os.Exit(1)
+	}
+	info := enum_tools.ReportInfoFromLog(findings)
+
+	switch *format {
+	case "html":
+		if *out == "" {
+			fmt.Fprintln(console, "[!] An html report needs an output file (-o)")
+			os.Exit(1)
+		}
+		err = enum_tools.WriteHTMLReport(*out, info, findings)
+	case "markdown", "text":
+		var b bytes.Buffer
+		enum_tools.WriteSummary(&b, *format == "markdown", info, findings)
+		if *out == "" {
+			_, err = os.Stdout.Write(b.Bytes())
+		} else {
+			err = os.WriteFile(*out, b.Bytes(), 0644)
+		}
+	default:
+		fmt.Fprintf(console, "[!] Unknown report format %q\n", *format)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(console, "[!] %v\n", err)
+		os.Exit(1)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Main
 // ---------------------------------------------------------------------------
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		runSimulate(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		runReport(os.Args[2:])
+		return
+	}
+
 	args := parseArguments()
-	fmt.Print(banner)
 
-	// Status message.
-	fmt.Printf("Keywords:    %s\n", strings.Join(args.keywords, ", "))
-	if args.quickScan {
-		fmt.Println("Mutations:   NONE! (Using quickscan)")
-	} else {
-		if args.mutationsFile != "" {
-			fmt.Printf("Mutations:   %s\n", args.mutationsFile)
+	var dnsCache *enum_tools.DNSCache
+	if args.dnsCacheFile != "" {
+		var err error
+		if dnsCache, err = enum_tools.LoadDNSCache(args.dnsCacheFile); err != nil {
+			fmt.Fprintf(console, "[!] %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Mutations of the keywords, whose origins are recorded in findings.
+	var mutations []string
+	if args.subdomains == nil && args.assets == nil && !args.quickScan {
+		mutations = readMutations(args.mutationsFile)
+	}
+
+	// Build config for the scanner.
+	cfg := enum_tools.Config{
+		Threads:            args.threads,
+		Nameserver:         args.nameserver,
+		NameserverFile:     args.nameserverFile,
+		BruteData:          readFileOrEmbedded(args.bruteFile),
+		QuickScan:          args.quickScan,
+		RateLimitReqs:      args.rateLimitReqs,
+		RateLimitSleep:     time.Duration(args.rateLimitSleep) * time.Second,
+		RateLimit:          args.rateLimit,
+		ProviderRateLimits: args.providerLimits,
+		HostRateLimits:     args.hostLimits,
+		Checks:             args.checks,
+		SkipChecks:         args.skipChecks,
+		Output:             console,
+		StateFile:          args.stateFile,
+		Endpoints:          args.endpoints,
+		DNSOverride:        args.dnsOverride,
+		Proxy:              args.proxy,
+		ProxyDNS:           args.proxyDNS,
+		HTTPTimeout:        args.timeout,
+		HTTPRetries:        args.retries,
+		InsecureTLS:        args.insecure,
+		UserAgent:          args.userAgent,
+		Headers:            args.headers,
+		MaxBodySize:        args.maxBody,
+		DNSCache:           dnsCache,
+		Authoritative:      args.authoritative,
+		AuthoritativeQPS:   args.authQPS,
+		IPRanges:           args.ipRanges,
+		Rules:              args.rules,
+		NameOrigins:        enum_tools.NameOrigins(args.keywords, mutations),
+	}
+	scanner, err := enum_tools.NewScanner(cfg)
+	if err != nil {
+		fmt.Fprintf(console, "[!] %v\n", err)
+		os.Exit(1)
+	}
+
+	// Outputs of the findings, flushed and closed once the scan ends or is
+	// force-quit.
+	var outputs []output
+	for _, spec := range args.outputs {
+		format, path, _ := enum_tools.ParseSinkSpec(spec)
+		var sink enum_tools.Sink
+		if path == "-" {
+			sink, err = enum_tools.NewSink(format, os.Stdout)
 		} else {
-			fmt.Println("Mutations:   (embedded fuzz.txt)")
+			sink, err = enum_tools.OpenSink(format, path)
 		}
+		if err != nil {
+			fmt.Fprintf(console, "[!] %v\n", err)
+			os.Exit(1)
+		}
+		outputs = append(outputs, output{sink, path == "-"})
 	}
-	if args.bruteFile != "" {
-		fmt.Printf("Brute-list:  %s\n", args.bruteFile)
+	var closeOnce sync.Once
+	closeOutputs := func() {
+		closeOnce.Do(func() {
+			for _, o := range outputs {
+				if err := o.sink.Close(); err != nil {
+					fmt.Fprintf(console, "    [!] Could not write findings: %v\n", err)
+				}
+			}
+		})
+	}
+
+	fmt.Fprint(console, banner)
+
+	// Status message.
+	if args.subdomains != nil {
+		fmt.Fprintf(console, "Subdomains:  %d (dangling CNAME check)\n", len(args.subdomains))
+	} else if args.assets != nil {
+		fmt.Fprintf(console, "Assets:      %d (attribution)\n", len(args.assets))
+		if args.ipRanges != nil {
+			fmt.Fprintf(console, "IP ranges:   %d prefixes\n", args.ipRanges.Len())
+		}
 	} else {
-		fmt.Println("Brute-list:  (embedded fuzz.txt)")
+		fmt.Fprintf(console, "Keywords:    %s\n", strings.Join(args.keywords, ", "))
+		if args.quickScan {
+			fmt.Fprintln(console, "Mutations:   NONE! (Using quickscan)")
+		} else if args.mutationsFile != "" {
+			fmt.Fprintf(console, "Mutations:   %s\n", args.mutationsFile)
+		} else {
+			fmt.Fprintln(console, "Mutations:   (embedded fuzz.txt)")
+		}
+		if args.bruteFile != "" {
+			fmt.Fprintf(console, "Brute-list:  %s\n", args.bruteFile)
+		} else {
+			fmt.Fprintln(console, "Brute-list:  (embedded fuzz.txt)")
+		}
 	}
-	fmt.Println()
+	fmt.Fprintln(console)
 
-	// Initialise rate limiter.
 	if args.rateLimitReqs > 0 {
-		enum_tools.InitRateLimiter(args.rateLimitReqs, time.Duration(args.rateLimitSleep)*time.Second)
-		fmt.Printf("Rate-limit: sleep %ds every %d HTTP requests\n", args.rateLimitSleep, args.rateLimitReqs)
+		fmt.Fprintf(console, "Rate-limit: sleep %ds every %d HTTP requests\n", args.rateLimitSleep, args.rateLimitReqs)
+	}
+	if args.rateLimit.RPS > 0 {
+		fmt.Fprintf(console, "Rate-limit: %v\n", args.rateLimit)
+	}
+	for target, rl := range args.providerLimits {
+		fmt.Fprintf(console, "Rate-limit: %s %v\n", target, rl)
+	}
+	for target, rl := range args.hostLimits {
+		fmt.Fprintf(console, "Rate-limit: *.%s %v\n", target, rl)
+	}
+	if args.authoritative {
+		fmt.Fprintf(console, "DNS:         authoritative nameservers, %g queries/s each\n", args.authQPS)
+	}
+	if dnsCache != nil {
+		fmt.Fprintf(console, "DNS cache:   %s (%d answers)\n", args.dnsCacheFile, dnsCache.Len())
+	}
+	if args.stateFile != "" {
+		fmt.Fprintf(console, "State file:  %s\n", args.stateFile)
+	}
+	for service, ep := range args.endpoints {
+		fmt.Fprintf(console, "Endpoint:    %s -> %s\n", service, ep.URL("{name}", "{region}", "", "http"))
+	}
+	if args.dnsOverride != "" {
+		fmt.Fprintf(console, "DNS:         %s (override)\n", args.dnsOverride)
+	}
+	if proxy, err := enum_tools.ParseProxy(args.proxy); args.proxy != "" && err == nil {
+		fmt.Fprintf(console, "Proxy:       %s\n", proxy.Redacted())
+		if args.proxyDNS {
+			fmt.Fprintln(console, "Proxy:       DNS over TCP through the proxy")
+		}
 	}
 
-	// Build mutated name list.
-	var mutations []string
-	if !args.quickScan {
-		mutations = readMutations(args.mutationsFile)
+	if args.insecure {
+		fmt.Fprintln(console, "TLS:         certificates not verified")
+	}
+	if args.userAgent != "" {
+		fmt.Fprintf(console, "User-Agent:  %s\n", args.userAgent)
+	}
+	for name := range args.headers {
+		fmt.Fprintf(console, "Header:      %s\n", name)
 	}
-	names := buildNames(args.keywords, mutations)
 
-	// Build config for check modules.
-	bruteData := readFileOrEmbedded(args.bruteFile)
-	cfg := &enum_tools.Config{
-		Threads:        args.threads,
-		Nameserver:     args.nameserver,
-		NameserverFile: args.nameserverFile,
-		BruteData:      bruteData,
-		QuickScan:      args.quickScan,
+	ctx, stop := interruptContext(closeOutputs)
+	defer stop()
+
+	// The terminal and the log file consume the findings.
+	var findings []enum_tools.Finding
+	info := enum_tools.ReportInfo{Started: time.Now()}
+	report := func(f enum_tools.Finding) {
+		findings = append(findings, f)
+		enum_tools.FmtFinding(console, f)
+		for _, o := range outputs {
+			// Restored findings were logged by the run that found them;
+			// streams get every finding of this run.
+			if o.stream || !f.Restored {
+				_ = o.sink.Write(f)
+			}
+		}
 	}
+	if args.subdomains != nil {
+		info.Mode, info.Targets = "takeover", len(args.subdomains)
+		err = scanner.Takeover(ctx, args.subdomains, report)
+	} else if args.assets != nil {
+		info.Mode, info.Targets = "attribution", len(args.assets)
+		err = scanner.Attribute(ctx, args.assets, report)
+	} else {
+		// Build mutated name list.
+		if !args.quickScan {
+			fmt.Fprintf(console, "[+] Mutations list imported: %d items\n", len(mutations))
+		}
+		names := enum_tools.BuildNames(args.keywords, mutations)
+		fmt.Fprintf(console, "[+] Mutated results: %d items\n", len(names))
 
-	// Run checks.
-	if !args.disableAWS {
-		enum_tools.RunAllAWS(names, cfg)
+		info.Mode, info.Keywords, info.Mutations, info.Targets = "keywords", args.keywords, len(mutations), len(names)
+		info.Regions = map[string][]string{"azure": enum_tools.AzureRegions, "gcp": enum_tools.GCPRegions}
+		err = scanner.Run(ctx, names, report)
+	}
+	printStats(scanner)
+	closeOutputs()
+	if args.report != "" || args.summary != "" {
+		info.RunID = scanner.RunID()
+		info.Duration = time.Since(info.Started)
+		info.Interrupted = errors.Is(err, context.Canceled)
+		info.Timings = scanner.Timings()
+		info.Stats = scanner.Stats()
+		if err != nil && !info.Interrupted {
+			info.Errors = append(info.Errors, err.Error())
+		}
+		for _, rs := range scanner.ResolverStats() {
+			if rs.Evicted != "" {
+				info.Errors = append(info.Errors, fmt.Sprintf("nameserver %s dropped (%s)", rs.Server, rs.Evicted))
+			}
+		}
+		writeReports(args, info, findings)
 	}
-	if !args.disableAzure {
-		enum_tools.RunAllAzure(names, cfg)
+	if dnsCache != nil {
+		if cacheErr := dnsCache.Save(); cacheErr != nil {
+			fmt.Fprintf(console, "    [!] Could not save the DNS cache: %v\n", cacheErr)
+		}
 	}
-	if !args.disableGCP {
-		enum_tools.RunAllGCP(names, cfg)
+	if errors.Is(err, context.Canceled) {
+		printSummary(findings)
+		stop()
+		os.Exit(exitInterrupted)
+	}
+	if err != nil {
+		fmt.Fprintf(console, "\n    [!] %v\n", err)
+		if errors.Is(err, enum_tools.ErrNameserver) {
+			fmt.Fprintln(console, "    [!] If you're using a VPN, try setting -ns to your VPN's nameserver.")
+		}
+		os.Exit(1)
 	}
 
-	fmt.Println("\n[+] All done, happy hacking!")
+	fmt.Fprintln(console, "\n[+] All done, happy hacking!")
 }